@@ -7,7 +7,10 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/axatol/actions-job-dispatcher/pkg/cache"
 	"github.com/axatol/actions-job-dispatcher/pkg/config"
+	"github.com/axatol/actions-job-dispatcher/pkg/controller"
+	"github.com/axatol/actions-job-dispatcher/pkg/gh"
 	"github.com/axatol/actions-job-dispatcher/pkg/k8s"
 	"github.com/axatol/actions-job-dispatcher/pkg/server"
 	"github.com/axatol/actions-job-dispatcher/pkg/util"
@@ -29,6 +32,94 @@ func printVersion() {
 		Send()
 }
 
+// failures are retried on the next tick
+func reconcile(ctx context.Context) {
+	if !k8s.IsLeader() {
+		log.Debug().Str("leader", k8s.Leader()).Msg("not the leader, skipping reconciliation")
+		return
+	}
+
+	if err := controller.Reconcile(ctx); err != nil {
+		log.Error().Err(err).Msg("could not reconcile")
+	}
+}
+
+func collectGarbage(ctx context.Context) {
+	if !k8s.IsLeader() {
+		return
+	}
+
+	removed, err := controller.CollectGarbage(ctx)
+	if err != nil {
+		log.Error().Err(err).Msg("could not collect garbage")
+	}
+
+	for _, runner := range removed {
+		log.Info().
+			Int64("runner_id", runner.ID).
+			Str("runner_name", runner.Name).
+			Str("runner_scope", runner.Scope).
+			Msg("removed offline runner")
+	}
+}
+
+func expireCache() {
+	if !k8s.IsLeader() {
+		return
+	}
+
+	for _, meta := range cache.Expire() {
+		log.Info().
+			Int64("workflow_job_id", meta.WorkflowJobID).
+			Str("workflow_job_url", meta.WorkflowJobURL).
+			Time("expires_at", meta.ExpiresAt()).
+			Msg("expired cached workflow job")
+	}
+}
+
+func reapUnregistered(ctx context.Context) {
+	if !k8s.IsLeader() {
+		return
+	}
+
+	if _, err := controller.ReapUnregistered(ctx); err != nil {
+		log.Error().Err(err).Msg("could not reap unregistered runners")
+	}
+}
+
+func recoverDeliveries(ctx context.Context) {
+	if !k8s.IsLeader() {
+		return
+	}
+
+	if err := gh.RecoverDeliveries(ctx, controller.ReplayDelivery); err != nil {
+		log.Error().Err(err).Msg("could not recover failed deliveries")
+	}
+}
+
+func poll(ctx context.Context) {
+	if !k8s.IsLeader() {
+		return
+	}
+
+	if err := controller.Poll(ctx); err != nil {
+		log.Error().Err(err).Msg("could not poll workflow jobs")
+	}
+}
+
+// followers neither cache events nor write the store, so a new leader first
+// recovers jobs queued before it took over
+func startLeading(ctx context.Context) {
+	if config.Mode == config.PollMode {
+		// the first poll picks up everything already queued
+		poll(ctx)
+	} else if err := controller.Backfill(ctx); err != nil {
+		log.Error().Err(err).Msg("could not backfill workflow jobs")
+	}
+
+	reconcile(ctx)
+}
+
 func main() {
 	config.LoadConfig()
 
@@ -52,6 +143,24 @@ func main() {
 		}
 	})
 
+	// workflow jobs survive restarts unless kept in memory
+	if err := cache.Open(ctx); err != nil {
+		log.Fatal().Err(fmt.Errorf("could not open store: %s", err)).Send()
+	}
+
+	// local cache of managed jobs and pods
+	if err := k8s.StartInformer(ctx); err != nil {
+		log.Fatal().Err(fmt.Errorf("could not start informer: %s", err)).Send()
+	}
+
+	if err := controller.WatchJobs(); err != nil {
+		log.Fatal().Err(fmt.Errorf("could not watch jobs: %s", err)).Send()
+	}
+
+	if err := controller.WatchPods(); err != nil {
+		log.Fatal().Err(fmt.Errorf("could not watch pods: %s", err)).Send()
+	}
+
 	// start the server
 	go func() {
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -61,6 +170,9 @@ func main() {
 
 	log.Info().
 		Bool("dry_run", config.DryRun).
+		Str("mode", config.Mode.String()).
+		Str("store", config.Store.String()).
+		Bool("leader_election", config.LeaderElection).
 		Bool("github_token_auth", config.Github.IsToken()).
 		Bool("github_app_auth", config.Github.IsApp()).
 		Str("log_level", log.Logger.GetLevel().String()).
@@ -69,25 +181,66 @@ func main() {
 		Str("kubernetes_server", serverVersion.GitVersion).
 		Strs("serving_runner_labels", config.Runners.Strs()).
 		Dur("sync_interval", config.SyncInterval).
+		Int("dispatch_workers", config.DispatchWorkers).
+		Dur("delivery_recovery_interval", config.DeliveryRecoveryInterval).
 		Msgf("server started at http://localhost:%d", config.ServerPort)
 
-	// TODO first time reconcile
-	// if err := controller.Reconcile(ctx); err != nil {
-	// 	log.Fatal().Err(err).Msg("could not reconcile")
-	// }
+	controller.StartDispatchWorkers(ctx, config.DispatchWorkers)
+
+	if config.LeaderElection {
+		// catch up and reconcile as soon as leadership is acquired
+		go func() {
+			if err := k8s.RunLeaderElection(ctx, startLeading); err != nil {
+				log.Error().Err(err).Msg("leader election failed")
+				cancel()
+			}
+		}()
+	} else {
+		startLeading(ctx)
+	}
 
 	ticker := time.NewTicker(config.SyncInterval)
+	gcTicker := time.NewTicker(config.GCInterval)
+
+	// only polls in poll mode
+	var pollTick <-chan time.Time
+	if config.Mode == config.PollMode {
+		pollTicker := time.NewTicker(config.PollInterval)
+		defer pollTicker.Stop()
+		pollTick = pollTicker.C
+	}
+
+	// disabled unless an interval is set
+	var recoveryTick <-chan time.Time
+	if config.DeliveryRecoveryInterval > 0 && len(config.Hooks) > 0 {
+		recoveryTicker := time.NewTicker(config.DeliveryRecoveryInterval)
+		defer recoveryTicker.Stop()
+		recoveryTick = recoveryTicker.C
+	}
+
 	for loop := true; loop; {
 		select {
 		case <-ctx.Done():
 			loop = false
 		case <-ticker.C:
-			// TODO regular reconciliation
-			// if err := controller.Reconcile(ctx); err != nil {
-			// 	log.Fatal().Err(err).Msg("could not reconcile")
-			// }
+			// regular reconciliation
+			reconcile(ctx)
+		case <-controller.Triggered():
+			// job events
+			reconcile(ctx)
+		case <-gcTicker.C:
+			collectGarbage(ctx)
+			reapUnregistered(ctx)
+			expireCache()
+		case <-pollTick:
+			poll(ctx)
+		case <-recoveryTick:
+			recoverDeliveries(ctx)
 		}
 	}
 
+	ticker.Stop()
+	gcTicker.Stop()
+
 	log.Info().Err(ctx.Err()).Msg("dispatcher exiting")
 }
@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltBucket        = []byte("workflow_jobs")
+	boltHistoryBucket = []byte("workflow_job_history")
+)
+
+// persists to a local file, which is locked by a single replica at a time
+type boltStore struct {
+	db       *bolt.DB
+	bucket   []byte
+	watchers watchers
+}
+
+func newBoltStore(ctx context.Context, path string) (*boltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %s", path, err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{boltBucket, boltHistoryBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create buckets in %s: %s", path, err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		if err := db.Close(); err != nil {
+			log.Error().Err(err).Str("path", path).Msg("failed to close bolt store")
+		}
+	}()
+
+	return &boltStore{db: db, bucket: boltBucket}, nil
+}
+
+// a store backed by another bucket of the same file
+func (s *boltStore) withBucket(bucket []byte) *boltStore {
+	return &boltStore{db: s.db, bucket: bucket}
+}
+
+func boltKey(id int64) []byte {
+	return []byte(fmt.Sprint(id))
+}
+
+func (s *boltStore) Get(id int64) (*WorkflowJobMeta, error) {
+	var meta *WorkflowJobMeta
+	err := s.db.View(func(tx *bolt.Tx) error {
+		raw := tx.Bucket(s.bucket).Get(boltKey(id))
+		if raw == nil {
+			return nil
+		}
+
+		meta = &WorkflowJobMeta{}
+		return json.Unmarshal(raw, meta)
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get workflow job %d: %s", id, err)
+	}
+
+	return meta, nil
+}
+
+func (s *boltStore) Set(meta WorkflowJobMeta) error {
+	raw, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to marshal workflow job %d: %s", meta.WorkflowJobID, err)
+	}
+
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).Put(boltKey(meta.WorkflowJobID), raw)
+	}); err != nil {
+		return fmt.Errorf("failed to set workflow job %d: %s", meta.WorkflowJobID, err)
+	}
+
+	s.watchers.notify(Event{EventSet, meta})
+	return nil
+}
+
+func (s *boltStore) Del(id int64) error {
+	meta, err := s.Get(id)
+	if err != nil || meta == nil {
+		return err
+	}
+
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).Delete(boltKey(id))
+	}); err != nil {
+		return fmt.Errorf("failed to delete workflow job %d: %s", id, err)
+	}
+
+	s.watchers.notify(Event{EventDel, *meta})
+	return nil
+}
+
+func (s *boltStore) List() ([]WorkflowJobMeta, error) {
+	results := []WorkflowJobMeta{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).ForEach(func(_, raw []byte) error {
+			var meta WorkflowJobMeta
+			if err := json.Unmarshal(raw, &meta); err != nil {
+				return err
+			}
+
+			results = append(results, meta)
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list workflow jobs: %s", err)
+	}
+
+	return results, nil
+}
+
+func (s *boltStore) Watch(ctx context.Context) <-chan Event {
+	return s.watchers.add(ctx)
+}
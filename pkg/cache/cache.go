@@ -1,50 +1,104 @@
 package cache
 
 import (
+	"context"
+	"sync"
 	"time"
 
 	"github.com/google/go-github/v51/github"
 	"github.com/rs/zerolog/log"
 )
 
-var cache map[int64]WorkflowJobMeta
+// serialises read-modify-write updates, the store itself is goroutine-safe
+var eventLock sync.Mutex
 
+// unexpired workflow jobs
 func List() []WorkflowJobMeta {
-	results := []WorkflowJobMeta{}
-	for _, meta := range cache {
-		results = append(results, meta)
+	results, err := store.List()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list cached workflow jobs")
+		return []WorkflowJobMeta{}
 	}
 
-	return results
+	now := time.Now()
+	unexpired := []WorkflowJobMeta{}
+	for _, meta := range results {
+		if !meta.Expired(now) {
+			unexpired = append(unexpired, meta)
+		}
+	}
+
+	return unexpired
 }
 
-func Set(meta WorkflowJobMeta) WorkflowJobMeta {
-	if cache == nil {
-		cache = map[int64]WorkflowJobMeta{}
+func Set(meta WorkflowJobMeta) error {
+	if err := store.Set(meta); err != nil {
+		log.Error().Err(err).Int64("workflow_job_id", meta.WorkflowJobID).Msg("failed to cache workflow job")
+		return err
 	}
 
-	cache[meta.WorkflowJobID] = meta
-	return meta
+	return nil
 }
 
 func Get(id int64) *WorkflowJobMeta {
-	if meta, ok := cache[id]; ok {
-		return &meta
+	meta, err := store.Get(id)
+	if err != nil {
+		log.Error().Err(err).Int64("workflow_job_id", id).Msg("failed to get cached workflow job")
+		return nil
 	}
 
-	return nil
+	if meta != nil && meta.Expired(time.Now()) {
+		return nil
+	}
+
+	return meta
 }
 
 func Del(id int64) {
-	delete(cache, id)
+	if err := store.Del(id); err != nil {
+		log.Error().Err(err).Int64("workflow_job_id", id).Msg("failed to delete cached workflow job")
+	}
+}
+
+func Watch(ctx context.Context) <-chan Event {
+	return store.Watch(ctx)
+}
+
+// removes workflow jobs whose completed webhook was most likely missed
+func Expire() []WorkflowJobMeta {
+	// an event may otherwise update a workflow job between listing and deleting
+	eventLock.Lock()
+	defer eventLock.Unlock()
+
+	results, err := store.List()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list cached workflow jobs")
+		return nil
+	}
+
+	now := time.Now()
+	expired := []WorkflowJobMeta{}
+	for _, meta := range results {
+		if meta.Expired(now) {
+			Del(meta.WorkflowJobID)
+			expired = append(expired, meta)
+		}
+	}
+
+	return expired
 }
 
 func CacheWorkflowJobEvent(event *github.WorkflowJobEvent) *WorkflowJobMeta {
+	eventLock.Lock()
+	defer eventLock.Unlock()
+
 	meta := Get(event.GetWorkflowJob().GetID())
 	if meta == nil {
 		meta = WorkflowJobMetaFromEvent(event)
 	}
 
+	meta.Timeline.stampWorkflowJob(event.GetWorkflowJob())
+
 	status := event.GetWorkflowJob().GetStatus()
 	switch status {
 	case "queued":
@@ -57,6 +111,7 @@ func CacheWorkflowJobEvent(event *github.WorkflowJobEvent) *WorkflowJobMeta {
 
 	case "completed":
 		Del(meta.WorkflowJobID)
+		addHistory(*meta)
 
 	default:
 		log.Warn().
@@ -0,0 +1,263 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/axatol/actions-job-dispatcher/pkg/k8s"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// configmaps are limited to 1MiB, leaving room for metadata
+	configMapMaxBytes = 1<<20 - 64<<10
+	// writes within this long of each other are batched into one update
+	configMapFlushInterval = time.Second
+)
+
+// shared between replicas through a configmap keyed by workflow job id, reads
+// are served from a local copy kept up to date by a watch. only the leader
+// writes, changes are applied to the local copy straight away and flushed to
+// the configmap in batches. configmaps are limited to 1MiB which fits a few
+// thousand workflow jobs
+type configMapStore struct {
+	ctx   context.Context
+	name  string
+	lock  sync.RWMutex
+	items map[int64]WorkflowJobMeta
+	// encoded size of each item
+	sizes map[int64]int
+	// changes not yet flushed and being flushed, nil values are deletes
+	dirty    map[string]*string
+	flushing map[string]*string
+	watchers watchers
+}
+
+func newConfigMapStore(ctx context.Context, name string) (*configMapStore, error) {
+	s := configMapStore{
+		ctx:   ctx,
+		name:  name,
+		items: map[int64]WorkflowJobMeta{},
+		sizes: map[int64]int{},
+		dirty: map[string]*string{},
+	}
+
+	if err := k8s.WatchConfigMap(ctx, name, s.sync); err != nil {
+		return nil, err
+	}
+
+	go s.run()
+	return &s, nil
+}
+
+// replaces the local copy with unflushed changes applied on top, and
+// notifies watchers of the differences
+func (s *configMapStore) sync(data map[string]string) {
+	s.lock.Lock()
+	merged := map[string]string{}
+	for key, raw := range data {
+		merged[key] = raw
+	}
+
+	for _, changes := range []map[string]*string{s.flushing, s.dirty} {
+		for key, raw := range changes {
+			if raw == nil {
+				delete(merged, key)
+			} else {
+				merged[key] = *raw
+			}
+		}
+	}
+
+	items := map[int64]WorkflowJobMeta{}
+	sizes := map[int64]int{}
+	for key, raw := range merged {
+		id, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		var meta WorkflowJobMeta
+		if err := json.Unmarshal([]byte(raw), &meta); err != nil {
+			log.Warn().Err(err).Str("configmap", s.name).Str("key", key).Msg("ignoring invalid workflow job")
+			continue
+		}
+
+		items[id] = meta
+		sizes[id] = len(key) + len(raw)
+	}
+
+	previous := s.items
+	s.items = items
+	s.sizes = sizes
+	s.lock.Unlock()
+
+	for id, meta := range items {
+		if prev, ok := previous[id]; !ok || !reflect.DeepEqual(prev, meta) {
+			s.watchers.notify(Event{EventSet, meta})
+		}
+	}
+
+	for id, meta := range previous {
+		if _, ok := items[id]; !ok {
+			s.watchers.notify(Event{EventDel, meta})
+		}
+	}
+}
+
+// flushes changes until the context is done, then flushes once more
+func (s *configMapStore) run() {
+	ticker := time.NewTicker(configMapFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flush(s.ctx)
+
+		case <-s.ctx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			s.flush(ctx)
+			cancel()
+			return
+		}
+	}
+}
+
+func (s *configMapStore) flush(ctx context.Context) {
+	s.lock.Lock()
+	changes := s.dirty
+	s.dirty = map[string]*string{}
+	s.flushing = changes
+	s.lock.Unlock()
+
+	if len(changes) < 1 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	configMap, err := k8s.UpdateConfigMap(ctx, s.name, func(data map[string]string) {
+		for key, raw := range changes {
+			if raw == nil {
+				delete(data, key)
+			} else {
+				data[key] = *raw
+			}
+		}
+	})
+
+	if err != nil {
+		log.Error().Err(err).Str("configmap", s.name).Int("changes", len(changes)).Msg("failed to flush workflow jobs, retrying")
+
+		// keep changes made since for the next attempt
+		s.lock.Lock()
+		for key, raw := range changes {
+			if _, ok := s.dirty[key]; !ok {
+				s.dirty[key] = raw
+			}
+		}
+		s.flushing = nil
+		s.lock.Unlock()
+		return
+	}
+
+	s.lock.Lock()
+	s.flushing = nil
+	s.lock.Unlock()
+
+	// do not wait for the watch to catch up
+	s.sync(configMap.Data)
+}
+
+func (s *configMapStore) size() int {
+	total := 0
+	for _, size := range s.sizes {
+		total += size
+	}
+
+	return total
+}
+
+func (s *configMapStore) Get(id int64) (*WorkflowJobMeta, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if meta, ok := s.items[id]; ok {
+		return &meta, nil
+	}
+
+	return nil, nil
+}
+
+func (s *configMapStore) Set(meta WorkflowJobMeta) error {
+	if !k8s.IsLeader() {
+		return fmt.Errorf("only the leader writes to configmap %s", s.name)
+	}
+
+	raw, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to marshal workflow job %d: %s", meta.WorkflowJobID, err)
+	}
+
+	key := fmt.Sprint(meta.WorkflowJobID)
+	value := string(raw)
+
+	s.lock.Lock()
+	size := s.size() - s.sizes[meta.WorkflowJobID] + len(key) + len(value)
+	if size > configMapMaxBytes {
+		s.lock.Unlock()
+		return fmt.Errorf("configmap %s would hold %d bytes, over the %d byte limit, lower the cache ttls or history size", s.name, size, configMapMaxBytes)
+	}
+
+	s.items[meta.WorkflowJobID] = meta
+	s.sizes[meta.WorkflowJobID] = len(key) + len(value)
+	s.dirty[key] = &value
+	s.lock.Unlock()
+
+	s.watchers.notify(Event{EventSet, meta})
+	return nil
+}
+
+func (s *configMapStore) Del(id int64) error {
+	if !k8s.IsLeader() {
+		return fmt.Errorf("only the leader writes to configmap %s", s.name)
+	}
+
+	s.lock.Lock()
+	meta, ok := s.items[id]
+	if ok {
+		delete(s.items, id)
+		delete(s.sizes, id)
+		s.dirty[fmt.Sprint(id)] = nil
+	}
+	s.lock.Unlock()
+
+	if ok {
+		s.watchers.notify(Event{EventDel, meta})
+	}
+
+	return nil
+}
+
+func (s *configMapStore) List() ([]WorkflowJobMeta, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	results := []WorkflowJobMeta{}
+	for _, meta := range s.items {
+		results = append(results, meta)
+	}
+
+	return results, nil
+}
+
+func (s *configMapStore) Watch(ctx context.Context) <-chan Event {
+	return s.watchers.add(ctx)
+}
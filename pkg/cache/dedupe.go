@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/axatol/actions-job-dispatcher/pkg/config"
+	"github.com/axatol/actions-job-dispatcher/pkg/util"
+)
+
+var (
+	dedupeOnce  sync.Once
+	deliveries  *util.TTLSet
+	transitions *util.TTLSet
+)
+
+func dedupe() {
+	dedupeOnce.Do(func() {
+		deliveries = util.NewTTLSet(config.DedupeTTL, config.DedupeSize)
+		transitions = util.NewTTLSet(config.DedupeTTL, config.DedupeSize)
+	})
+}
+
+// records a webhook delivery, false if it has already been seen
+func MarkDelivery(id string) bool {
+	dedupe()
+	return deliveries.Add(id)
+}
+
+// forgets a delivery that could not be processed so a redelivery is accepted
+func UnmarkDelivery(id string) {
+	dedupe()
+	deliveries.Del(id)
+}
+
+// records a workflow job status transition, false if it has already been seen,
+// repo and org hooks both deliver the same transition
+func MarkTransition(workflowJobID int64, action string) bool {
+	dedupe()
+	return transitions.Add(fmt.Sprintf("%d:%s", workflowJobID, action))
+}
+
+func UnmarkTransition(workflowJobID int64, action string) {
+	dedupe()
+	transitions.Del(fmt.Sprintf("%d:%s", workflowJobID, action))
+}
@@ -20,37 +20,70 @@ type WorkflowJobMeta struct {
 	RunnerLabels    []string  `json:"runner_labels"`
 	CreatedAt       time.Time `json:"queued_at"`
 	StartedAt       time.Time `json:"started_at"`
+	Timeline        Timeline  `json:"timeline"`
+}
+
+// when github gives up on the workflow job, queued jobs are cancelled after a
+// day and running jobs time out after their timeout-minutes, zero if unknown
+func (m WorkflowJobMeta) ExpiresAt() time.Time {
+	if m.StartedAt.After(m.CreatedAt) {
+		if config.InProgressTTL <= 0 {
+			return time.Time{}
+		}
+
+		return m.StartedAt.Add(config.InProgressTTL)
+	}
+
+	if m.CreatedAt.IsZero() || config.QueuedTTL <= 0 {
+		return time.Time{}
+	}
+
+	return m.CreatedAt.Add(config.QueuedTTL)
+}
+
+func (m WorkflowJobMeta) Expired(now time.Time) bool {
+	expiresAt := m.ExpiresAt()
+	return !expiresAt.IsZero() && now.After(expiresAt)
 }
 
 func MetaFromStringMap(m map[string]string) WorkflowJobMeta {
 	result := WorkflowJobMeta{}
-	result.IsOrg = m["is_org"] == "true"
-	result.Owner = m["owner"]
-	result.Repository = m["repository"]
-	if workflowID, err := strconv.ParseInt(m["workflow-id"], 10, 64); err != nil {
+	result.IsOrg = m["is-org"] == "true"
+	result.Owner = m["repository-owner"]
+	result.Repository = m["repository-name"]
+	if workflowID, err := strconv.ParseInt(m["workflow-id"], 10, 64); err == nil {
 		result.WorkflowID = workflowID
 	}
 	result.WorkflowName = m["workflow-name"]
-	if workflowJobID, err := strconv.ParseInt(m["workflow-job-id"], 10, 64); err != nil {
+	if workflowJobID, err := strconv.ParseInt(m["workflow-job-id"], 10, 64); err == nil {
 		result.WorkflowJobID = workflowJobID
 	}
 	result.WorkflowJobName = m["workflow-job-name"]
 	result.WorkflowJobURL = m["workflow-job-url"]
-	result.RunnerLabels = strings.Split(m["runner-labels"], ",")
+	if labels := m["workflow-job-labels"]; labels != "" {
+		result.RunnerLabels = strings.Split(labels, ",")
+	}
+	// github's queued time, not when the dispatcher first saw the job
+	if queuedAt, err := time.Parse(time.RFC3339, m["queued-at"]); err == nil {
+		Stamp(&result.Timeline.QueuedAt, queuedAt)
+	}
 	return result
 }
 
 func (m WorkflowJobMeta) StringMap() map[string]string {
 	result := map[string]string{}
-	result["is_org"] = strconv.FormatBool(m.IsOrg)
-	result["owner"] = m.Owner
-	result["repository"] = m.Repository
+	result["is-org"] = strconv.FormatBool(m.IsOrg)
+	result["repository-owner"] = m.Owner
+	result["repository-name"] = m.Repository
 	result["workflow-id"] = fmt.Sprint(m.WorkflowID)
 	result["workflow-name"] = m.WorkflowName
 	result["workflow-job-id"] = fmt.Sprint(m.WorkflowJobID)
 	result["workflow-job-name"] = m.WorkflowJobName
 	result["workflow-job-url"] = m.WorkflowJobURL
-	result["runner-labels"] = strings.Join(m.RunnerLabels, ",")
+	result["workflow-job-labels"] = strings.Join(m.RunnerLabels, ",")
+	if m.Timeline.QueuedAt != nil {
+		result["queued-at"] = m.Timeline.QueuedAt.Format(time.RFC3339)
+	}
 	return result
 }
 
@@ -67,3 +100,22 @@ func WorkflowJobMetaFromEvent(event *github.WorkflowJobEvent) *WorkflowJobMeta {
 	m.RunnerLabels = event.GetWorkflowJob().Labels
 	return &m
 }
+
+func WorkflowJobMetaFromJob(scope config.Scope, job *github.WorkflowJob) *WorkflowJobMeta {
+	m := WorkflowJobMeta{}
+	m.Scope = scope
+	m.WorkflowID = job.GetRunID()
+	m.WorkflowName = job.GetWorkflowName()
+	m.WorkflowJobID = job.GetID()
+	m.WorkflowJobName = job.GetName()
+	m.WorkflowJobURL = job.GetHTMLURL()
+	m.RunnerLabels = job.Labels
+	m.CreatedAt = job.GetCreatedAt().Time
+	if job.GetStatus() == "in_progress" {
+		m.StartedAt = job.GetStartedAt().Time
+	}
+
+	m.Timeline.stampWorkflowJob(job)
+
+	return &m
+}
@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/axatol/actions-job-dispatcher/pkg/config"
+)
+
+type Store interface {
+	Get(id int64) (*WorkflowJobMeta, error)
+	Set(meta WorkflowJobMeta) error
+	Del(id int64) error
+	List() ([]WorkflowJobMeta, error)
+	// changes to the store until ctx is done, including those made by other
+	// replicas if the store is shared
+	Watch(ctx context.Context) <-chan Event
+}
+
+type EventType string
+
+const (
+	EventSet EventType = "set"
+	EventDel EventType = "del"
+)
+
+type Event struct {
+	Type EventType
+	Meta WorkflowJobMeta
+}
+
+var (
+	store Store = newMemoryStore()
+	// completed workflow jobs, kept apart so they do not count as active
+	history Store = newMemoryStore()
+)
+
+// opens the store selected in config, must be called before the cache is used
+func Open(ctx context.Context) error {
+	switch config.Store {
+	case config.BoltStore:
+		s, err := newBoltStore(ctx, config.StorePath)
+		if err != nil {
+			return fmt.Errorf("failed to open bolt store: %s", err)
+		}
+
+		store = s
+		history = s.withBucket(boltHistoryBucket)
+
+	case config.ConfigMapStore:
+		s, err := newConfigMapStore(ctx, config.StoreConfigMapName)
+		if err != nil {
+			return fmt.Errorf("failed to open configmap store: %s", err)
+		}
+
+		h, err := newConfigMapStore(ctx, config.StoreConfigMapName+"-history")
+		if err != nil {
+			return fmt.Errorf("failed to open configmap history store: %s", err)
+		}
+
+		store = s
+		history = h
+
+	default:
+		store = newMemoryStore()
+		history = newMemoryStore()
+	}
+
+	return nil
+}
+
+// fans out store events to each watcher, slow watchers miss events
+type watchers struct {
+	lock  sync.Mutex
+	chans []chan Event
+}
+
+func (w *watchers) add(ctx context.Context) <-chan Event {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	ch := make(chan Event, 100)
+	w.chans = append(w.chans, ch)
+
+	go func() {
+		<-ctx.Done()
+		w.lock.Lock()
+		defer w.lock.Unlock()
+
+		for i, c := range w.chans {
+			if c == ch {
+				w.chans = append(w.chans[:i], w.chans[i+1:]...)
+				break
+			}
+		}
+
+		close(ch)
+	}()
+
+	return ch
+}
+
+func (w *watchers) notify(e Event) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for _, ch := range w.chans {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+type memoryStore struct {
+	lock     sync.RWMutex
+	items    map[int64]WorkflowJobMeta
+	watchers watchers
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{items: map[int64]WorkflowJobMeta{}}
+}
+
+func (s *memoryStore) Get(id int64) (*WorkflowJobMeta, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if meta, ok := s.items[id]; ok {
+		return &meta, nil
+	}
+
+	return nil, nil
+}
+
+func (s *memoryStore) Set(meta WorkflowJobMeta) error {
+	s.lock.Lock()
+	s.items[meta.WorkflowJobID] = meta
+	s.lock.Unlock()
+
+	s.watchers.notify(Event{EventSet, meta})
+	return nil
+}
+
+func (s *memoryStore) Del(id int64) error {
+	s.lock.Lock()
+	meta, ok := s.items[id]
+	delete(s.items, id)
+	s.lock.Unlock()
+
+	if ok {
+		s.watchers.notify(Event{EventDel, meta})
+	}
+
+	return nil
+}
+
+func (s *memoryStore) List() ([]WorkflowJobMeta, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	results := []WorkflowJobMeta{}
+	for _, meta := range s.items {
+		results = append(results, meta)
+	}
+
+	return results, nil
+}
+
+func (s *memoryStore) Watch(ctx context.Context) <-chan Event {
+	return s.watchers.add(ctx)
+}
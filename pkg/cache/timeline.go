@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"sort"
+	"time"
+
+	"github.com/axatol/actions-job-dispatcher/pkg/config"
+	"github.com/google/go-github/v51/github"
+	"github.com/rs/zerolog/log"
+)
+
+// when a workflow job reached each stage, nil if it has not (yet)
+type Timeline struct {
+	// github queued the workflow job
+	QueuedAt *time.Time `json:"queued_at,omitempty"`
+	// first webhook (or poll) for the workflow job
+	ReceivedAt *time.Time `json:"received_at,omitempty"`
+	// a runner was requested for the workflow job
+	DispatchRequestedAt *time.Time `json:"dispatch_requested_at,omitempty"`
+	// the runner job was created
+	JobCreatedAt *time.Time `json:"job_created_at,omitempty"`
+	// the runner pod was bound to a node
+	PodScheduledAt *time.Time `json:"pod_scheduled_at,omitempty"`
+	// the runner container started
+	ContainerStartedAt *time.Time `json:"container_started_at,omitempty"`
+	// a runner was assigned the workflow job
+	InProgressAt *time.Time `json:"in_progress_at,omitempty"`
+	CompletedAt  *time.Time `json:"completed_at,omitempty"`
+}
+
+// records the first time a stage was reached
+func Stamp(stage **time.Time, at time.Time) {
+	if *stage != nil || at.IsZero() {
+		return
+	}
+
+	at = at.UTC()
+	*stage = &at
+}
+
+// stamps github's own timestamps, falling back to now
+func (t *Timeline) stampWorkflowJob(job *github.WorkflowJob) {
+	now := time.Now()
+	Stamp(&t.QueuedAt, job.GetCreatedAt().Time)
+	Stamp(&t.ReceivedAt, now)
+
+	switch job.GetStatus() {
+	case "in_progress":
+		Stamp(&t.InProgressAt, job.GetStartedAt().Time)
+		Stamp(&t.InProgressAt, now)
+
+	case "completed":
+		// cancelled jobs never start
+		if !job.GetStartedAt().Time.IsZero() {
+			Stamp(&t.InProgressAt, job.GetStartedAt().Time)
+		}
+
+		Stamp(&t.CompletedAt, job.GetCompletedAt().Time)
+		Stamp(&t.CompletedAt, now)
+	}
+}
+
+// seconds spent between stages, nil unless both stages were reached
+type TimelineDurations struct {
+	// github queueing the job until the dispatcher heard about it
+	WebhookDelay *float64 `json:"webhook_delay_seconds,omitempty"`
+	// hearing about the job until a runner was requested
+	DispatchDelay *float64 `json:"dispatch_delay_seconds,omitempty"`
+	// requesting a runner until its container started
+	Provisioning *float64 `json:"provisioning_seconds,omitempty"`
+	// the runner container starting until github assigned it the job
+	Registration *float64 `json:"registration_seconds,omitempty"`
+	// github queueing the job until a runner was assigned
+	QueueWait *float64 `json:"queue_wait_seconds,omitempty"`
+	// a runner being assigned until the job completed
+	Runtime *float64 `json:"runtime_seconds,omitempty"`
+}
+
+func between(from, to *time.Time) *float64 {
+	if from == nil || to == nil {
+		return nil
+	}
+
+	seconds := to.Sub(*from).Seconds()
+	return &seconds
+}
+
+func (t Timeline) Durations() TimelineDurations {
+	return TimelineDurations{
+		WebhookDelay:  between(t.QueuedAt, t.ReceivedAt),
+		DispatchDelay: between(t.ReceivedAt, t.DispatchRequestedAt),
+		Provisioning:  between(t.DispatchRequestedAt, t.ContainerStartedAt),
+		Registration:  between(t.ContainerStartedAt, t.InProgressAt),
+		QueueWait:     between(t.QueuedAt, t.InProgressAt),
+		Runtime:       between(t.InProgressAt, t.CompletedAt),
+	}
+}
+
+// applies update to the timeline of a cached workflow job, false if it is not
+// cached
+func UpdateTimeline(id int64, update func(t *Timeline)) bool {
+	eventLock.Lock()
+	defer eventLock.Unlock()
+
+	meta := Get(id)
+	if meta == nil {
+		return false
+	}
+
+	// avoid writing to shared stores when nothing changed
+	before := meta.Timeline
+	update(&meta.Timeline)
+	if meta.Timeline != before {
+		Set(*meta)
+	}
+
+	return true
+}
+
+// keeps completed workflow jobs around for their timeline, evicting those
+// completed longest ago
+func addHistory(meta WorkflowJobMeta) {
+	if err := history.Set(meta); err != nil {
+		log.Error().Err(err).Int64("workflow_job_id", meta.WorkflowJobID).Msg("failed to add workflow job to history")
+		return
+	}
+
+	results, err := history.List()
+	if err != nil {
+		log.Error().Err(err).Msg("failed to list workflow job history")
+		return
+	}
+
+	overflow := len(results) - config.HistorySize
+	if overflow <= 0 {
+		return
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return completedAt(results[i]).Before(completedAt(results[j]))
+	})
+
+	for _, meta := range results[:overflow] {
+		if err := history.Del(meta.WorkflowJobID); err != nil {
+			log.Error().Err(err).Int64("workflow_job_id", meta.WorkflowJobID).Msg("failed to evict workflow job from history")
+		}
+	}
+}
+
+func completedAt(meta WorkflowJobMeta) time.Time {
+	if meta.Timeline.CompletedAt == nil {
+		return time.Time{}
+	}
+
+	return *meta.Timeline.CompletedAt
+}
+
+// a completed workflow job, nil if it is unknown or has been evicted
+func GetHistory(id int64) *WorkflowJobMeta {
+	meta, err := history.Get(id)
+	if err != nil {
+		log.Error().Err(err).Int64("workflow_job_id", id).Msg("failed to get workflow job history")
+		return nil
+	}
+
+	return meta
+}
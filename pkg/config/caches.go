@@ -0,0 +1,182 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// where setup-* actions look for and install toolchains
+	ToolCachePath = "/opt/hostedtoolcache"
+	// the dockerd data root, holding image layers and build cache
+	DockerDataPath = "/var/lib/docker"
+
+	// the runner user of the summerwind/actions-runner images, cache volumes
+	// are made group writable for it
+	DefaultFSGroup int64 = 1001
+)
+
+// volumes mounted into every runner pod to persist state between jobs
+type CacheVolumeList []CacheVolume
+
+func (l CacheVolumeList) Validate() error {
+	names := map[string]bool{}
+	paths := map[string]bool{}
+	toolCaches := 0
+	for _, cache := range l {
+		if err := cache.Validate(); err != nil {
+			return fmt.Errorf("invalid cache %s: %s", cache.Name, err)
+		}
+
+		if names[cache.Name] {
+			return fmt.Errorf("duplicate cache name %s", cache.Name)
+		}
+
+		if paths[path.Clean(cache.Path)] {
+			return fmt.Errorf("duplicate cache path %s", cache.Path)
+		}
+
+		if cache.ToolCache {
+			toolCaches++
+		}
+
+		names[cache.Name] = true
+		paths[path.Clean(cache.Path)] = true
+	}
+
+	if toolCaches > 1 {
+		return fmt.Errorf("only one cache may set tool_cache")
+	}
+
+	return nil
+}
+
+// the cache mounted at path, nil if there is none
+func (l CacheVolumeList) At(at string) *CacheVolume {
+	for _, cache := range l {
+		if path.Clean(cache.Path) == at {
+			return &cache
+		}
+	}
+
+	return nil
+}
+
+// the cache setup-* actions install toolchains into, nil if there is none
+func (l CacheVolumeList) ToolCache() *CacheVolume {
+	for _, cache := range l {
+		if cache.ToolCache {
+			return &cache
+		}
+	}
+
+	return l.At(ToolCachePath)
+}
+
+// exactly one of claim_name, ephemeral or host_path must be set
+type CacheVolume struct {
+	Name string `yaml:"name" json:"name"`
+	Path string `yaml:"path" json:"path"`
+
+	// an existing claim shared by all runners, should be ReadWriteMany
+	ClaimName string `yaml:"claim_name" json:"claim_name,omitempty"`
+	// a claim created for and deleted with each runner pod, set dataSource to
+	// clone it from a prepopulated claim or snapshot
+	Ephemeral *ClaimTemplate `yaml:"ephemeral" json:"ephemeral,omitempty"`
+	// a directory on the node, e.g. a local ssd, shared by runners on that node.
+	// it is created owned by root and fs_group is not applied, so it must be
+	// prepared writable for the runner user unless only dockerd writes to it.
+	// as the docker data root each pod gets its own subdirectory, which is not
+	// cleaned up
+	HostPath string `yaml:"host_path" json:"host_path,omitempty"`
+
+	// sets RUNNER_TOOL_CACHE to this path, implied for /opt/hostedtoolcache
+	ToolCache bool `yaml:"tool_cache" json:"tool_cache,omitempty"`
+}
+
+// whether each pod mounts its own subdirectory of the volume
+func (c CacheVolume) PerPod() bool {
+	return c.HostPath != "" && path.Clean(c.Path) == DockerDataPath
+}
+
+func (c CacheVolume) Validate() error {
+	if errs := validation.IsDNS1123Label(c.Name); len(errs) > 0 {
+		return fmt.Errorf("invalid name: %s", strings.Join(errs, ", "))
+	}
+
+	// volume names are prefixed and limited to a dns label
+	if len(c.Name) > validation.DNS1123LabelMaxLength-len("cache-") {
+		return fmt.Errorf("name must be no more than %d characters", validation.DNS1123LabelMaxLength-len("cache-"))
+	}
+
+	if !path.IsAbs(c.Path) {
+		return fmt.Errorf("path must be absolute, got %q", c.Path)
+	}
+
+	sources := 0
+	if c.ClaimName != "" {
+		sources++
+		if errs := validation.IsDNS1123Subdomain(c.ClaimName); len(errs) > 0 {
+			return fmt.Errorf("invalid claim_name: %s", strings.Join(errs, ", "))
+		}
+	}
+
+	if c.Ephemeral != nil {
+		sources++
+		if err := c.Ephemeral.Validate(); err != nil {
+			return fmt.Errorf("invalid ephemeral: %s", err)
+		}
+	}
+
+	if c.HostPath != "" {
+		sources++
+		if !path.IsAbs(c.HostPath) {
+			return fmt.Errorf("host_path must be absolute, got %q", c.HostPath)
+		}
+	}
+
+	if sources != 1 {
+		return fmt.Errorf("must set exactly one of [claim_name, ephemeral, host_path]")
+	}
+
+	// concurrent dockerd instances corrupt a shared data root
+	if c.ClaimName != "" && path.Clean(c.Path) == DockerDataPath {
+		return fmt.Errorf("claim_name is shared between runners and cannot hold %s, use ephemeral or host_path", DockerDataPath)
+	}
+
+	return nil
+}
+
+// the spec of the claim created for each runner pod
+type ClaimTemplate corev1.PersistentVolumeClaimSpec
+
+func (t *ClaimTemplate) UnmarshalYAML(node *yaml.Node) error {
+	return decodeThroughJSON(node, (*corev1.PersistentVolumeClaimSpec)(t))
+}
+
+func (t ClaimTemplate) Validate() error {
+	if _, ok := t.Resources.Requests[corev1.ResourceStorage]; !ok {
+		return fmt.Errorf("must request storage in resources.requests.storage")
+	}
+
+	if t.StorageClassName != nil && *t.StorageClassName != "" {
+		if errs := validation.IsDNS1123Subdomain(*t.StorageClassName); len(errs) > 0 {
+			return fmt.Errorf("invalid storageClassName: %s", strings.Join(errs, ", "))
+		}
+	}
+
+	for _, mode := range t.AccessModes {
+		switch mode {
+		case corev1.ReadWriteOnce, corev1.ReadOnlyMany, corev1.ReadWriteMany, corev1.ReadWriteOncePod:
+		default:
+			return fmt.Errorf("invalid access mode %s", mode)
+		}
+	}
+
+	return nil
+}
@@ -17,6 +17,7 @@ var (
 
 	configFile string
 	DryRun     bool
+	Mode       modeValue
 	logLevel   logLevelValue
 	logFormat  logFormatValue
 
@@ -39,6 +40,49 @@ var (
 	SyncInterval time.Duration
 	Runners      RunnerConfigList
 
+	// workflow job cache
+
+	Store              storeValue
+	StorePath          string
+	StoreConfigMapName string
+	QueuedTTL          time.Duration
+	InProgressTTL      time.Duration
+	HistorySize        int
+
+	// polling
+
+	PollInterval         time.Duration
+	PollRateLimitReserve int
+
+	// dispatcher
+
+	DispatchWorkers    int
+	DispatchMaxRetries int
+
+	// webhooks
+
+	DedupeTTL  time.Duration
+	DedupeSize int
+
+	// delivery recovery
+
+	Hooks                    HookConfigList
+	DeliveryRecoveryInterval time.Duration
+	DeliveryRecoveryWindow   time.Duration
+	DeliveryRecoveryMode     deliveryRecoveryModeValue
+
+	// garbage collection
+
+	GCInterval          time.Duration
+	GCGracePeriod       time.Duration
+	RegistrationTimeout time.Duration
+
+	// leader election
+
+	LeaderElection          bool
+	LeaderElectionLeaseName string
+	LeaderElectionIdentity  string
+
 	// metadata
 
 	PrintVersion bool
@@ -48,6 +92,7 @@ func LoadConfig() {
 	fs := flagSet{flag.CommandLine}
 	fs.StringVar(&configFile, "config", "", "path to config")
 	fs.BoolVar(&DryRun, "dry-run", false, "dry run")
+	fs.Var(&Mode, "mode", "how workflow jobs are discovered, webhook or poll")
 	fs.Var(&logLevel, "log-level", "log level")
 	fs.Var(&logFormat, "log-format", "log format")
 	fs.Int64Var(&ServerPort, "server-port", 8000, "server port")
@@ -59,6 +104,27 @@ func LoadConfig() {
 	fs.StringVar(&KubeContext, "kube-context", KubeContext, "specific a kubernetes context")
 	fs.StringVar(&Namespace, "namespace", "actions-runners", "specify a kubernetes namespace")
 	fs.DurationVar(&SyncInterval, "sync-interval", time.Minute*5, "sync interval")
+	fs.Var(&Store, "store", "where the workflow job cache is kept, memory, bolt or configmap")
+	fs.StringVar(&StorePath, "store-path", "cache.db", "path to the bolt store file")
+	fs.StringVar(&StoreConfigMapName, "store-configmap-name", "actions-job-dispatcher-cache", "name of the configmap store")
+	fs.DurationVar(&QueuedTTL, "cache-queued-ttl", time.Hour*24, "how long a queued workflow job is cached, github cancels jobs queued for a day, 0 disables")
+	fs.DurationVar(&InProgressTTL, "cache-in-progress-ttl", time.Minute*360, "how long an in progress workflow job is cached, matches the default job timeout-minutes, 0 disables")
+	fs.IntVar(&HistorySize, "cache-history-size", 1000, "number of completed workflow jobs kept for their timeline")
+	fs.DurationVar(&PollInterval, "poll-interval", time.Minute, "interval between polling for workflow jobs in poll mode, github caches responses for a minute")
+	fs.IntVar(&PollRateLimitReserve, "poll-rate-limit-reserve", 500, "api requests kept in reserve for dispatching, polling pauses below this")
+	fs.IntVar(&DispatchWorkers, "dispatch-workers", 2, "number of concurrent dispatch workers")
+	fs.IntVar(&DispatchMaxRetries, "dispatch-max-retries", 5, "number of times a failed dispatch is retried")
+	fs.DurationVar(&DedupeTTL, "dedupe-ttl", time.Hour, "how long webhook deliveries are remembered for de-duplication")
+	fs.IntVar(&DedupeSize, "dedupe-size", 10000, "maximum number of webhook deliveries remembered for de-duplication")
+	fs.DurationVar(&DeliveryRecoveryInterval, "delivery-recovery-interval", 0, "interval between checking configured hooks for failed deliveries, 0 disables")
+	fs.DurationVar(&DeliveryRecoveryWindow, "delivery-recovery-window", time.Hour, "how far back failed deliveries are recovered")
+	fs.Var(&DeliveryRecoveryMode, "delivery-recovery-mode", "how failed deliveries are recovered, redeliver or replay")
+	fs.DurationVar(&GCInterval, "gc-interval", time.Minute*5, "interval between removing offline runners")
+	fs.DurationVar(&GCGracePeriod, "gc-grace-period", time.Minute*10, "how long a runner must be offline without a job before it is removed")
+	fs.DurationVar(&RegistrationTimeout, "registration-timeout", time.Minute*10, "how long a runner job has to register with github before it is replaced")
+	fs.BoolVar(&LeaderElection, "leader-election", false, "enable leader election to run multiple replicas")
+	fs.StringVar(&LeaderElectionLeaseName, "leader-election-lease-name", "actions-job-dispatcher", "name of the lease used for leader election")
+	fs.StringVar(&LeaderElectionIdentity, "leader-election-identity", "", "host:port followers forward webhooks to, defaults to hostname:server-port")
 	fs.BoolVar(&PrintVersion, "version", false, "prints current version")
 
 	// flags first priority
@@ -70,6 +136,11 @@ func LoadConfig() {
 	// config file lowest priority
 	loadConfigFromFile()
 
+	if LeaderElectionIdentity == "" {
+		hostname, _ := os.Hostname()
+		LeaderElectionIdentity = fmt.Sprintf("%s:%d", hostname, ServerPort)
+	}
+
 	zerolog.SetGlobalLevel(zerolog.Level(logLevel))
 	if logFormat == logFormatValue(textLogFormat) {
 		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stdout})
@@ -97,6 +168,7 @@ func loadConfigFromFile() {
 		var cfg struct {
 			Github  GithubConfig   `yaml:"github"`
 			Runners []RunnerConfig `yaml:"runners"`
+			Hooks   []HookConfig   `yaml:"hooks"`
 		}
 
 		if err := yaml.Unmarshal(raw, &cfg); err != nil {
@@ -116,5 +188,10 @@ func loadConfigFromFile() {
 		if err := Runners.Validate(); err != nil {
 			panic(fmt.Errorf("failed to validate runners: %s", err))
 		}
+
+		Hooks = cfg.Hooks
+		if err := Hooks.Validate(); err != nil {
+			panic(fmt.Errorf("failed to validate hooks: %s", err))
+		}
 	}
 }
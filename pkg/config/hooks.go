@@ -0,0 +1,88 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+type HookConfigList []HookConfig
+
+func (hcl HookConfigList) Validate() error {
+	for _, hook := range hcl {
+		if err := hook.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// a repo or org webhook whose deliveries are checked for failures
+type HookConfig struct {
+	ID    int64 `yaml:"id"    json:"id"`
+	Scope Scope `yaml:"scope" json:"scope"`
+}
+
+func (c HookConfig) String() string {
+	return fmt.Sprintf("%s:%d", c.Scope.String(), c.ID)
+}
+
+func (c HookConfig) Validate() error {
+	if c.ID < 1 {
+		return fmt.Errorf("must specify hook id for %s", c.Scope.String())
+	}
+
+	if err := c.Scope.Validate(); err != nil {
+		return fmt.Errorf("invalid scope for hook %d: %s", c.ID, err)
+	}
+
+	return nil
+}
+
+type deliveryRecoveryModeValue string
+
+const (
+	// ask github to deliver the payload again
+	RedeliverRecoveryMode deliveryRecoveryModeValue = "redeliver"
+	// fetch the payload and process it in place
+	ReplayRecoveryMode deliveryRecoveryModeValue = "replay"
+)
+
+func (v *deliveryRecoveryModeValue) Default() string {
+	return string(RedeliverRecoveryMode)
+}
+
+func (v *deliveryRecoveryModeValue) Set(s string) error {
+	val := deliveryRecoveryModeValue(s)
+	if err := val.Validate(); err != nil {
+		return err
+	}
+
+	*v = val
+	return nil
+}
+
+func (v *deliveryRecoveryModeValue) String() string {
+	if v == nil {
+		return v.Default()
+	}
+
+	return string(*v)
+}
+
+func (t deliveryRecoveryModeValue) Values() []string {
+	return []string{
+		string(RedeliverRecoveryMode),
+		string(ReplayRecoveryMode),
+	}
+}
+
+func (t deliveryRecoveryModeValue) Validate() error {
+	for _, v := range t.Values() {
+		if t == deliveryRecoveryModeValue(v) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("delivery recovery mode must be one of [%s], got %s", strings.Join(t.Values(), ", "), t)
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+type LabelPatternList []LabelPattern
+
+func (lpl LabelPatternList) Validate() error {
+	for i, pattern := range lpl {
+		if err := pattern.Validate(); err != nil {
+			return fmt.Errorf("invalid pattern %d: %s", i, err)
+		}
+	}
+
+	return nil
+}
+
+func (lpl LabelPatternList) Strs() []string {
+	results := []string{}
+	for _, pattern := range lpl {
+		results = append(results, pattern.String())
+	}
+
+	return results
+}
+
+// index of the first pattern matching the label, -1 if none match
+func (lpl LabelPatternList) Match(label string, fold bool) int {
+	for i, pattern := range lpl {
+		if pattern.Match(label, fold) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// matches a family of labels, regular expressions are anchored
+type LabelPattern struct {
+	Glob  string `yaml:"glob"  json:"glob,omitempty"`
+	Regex string `yaml:"regex" json:"regex,omitempty"`
+}
+
+func (lp LabelPattern) String() string {
+	if lp.Regex != "" {
+		return fmt.Sprintf("/%s/", lp.Regex)
+	}
+
+	return lp.Glob
+}
+
+func (lp LabelPattern) regexp(fold bool) (*regexp.Regexp, error) {
+	expr := fmt.Sprintf("^(?:%s)$", lp.Regex)
+	if fold {
+		expr = "(?i)" + expr
+	}
+
+	return regexp.Compile(expr)
+}
+
+func (lp LabelPattern) Validate() error {
+	if (lp.Glob == "") == (lp.Regex == "") {
+		return fmt.Errorf("must specify one of glob or regex")
+	}
+
+	if lp.Glob != "" {
+		if _, err := path.Match(lp.Glob, ""); err != nil {
+			return fmt.Errorf("invalid glob %s: %s", lp.Glob, err)
+		}
+
+		return nil
+	}
+
+	if _, err := lp.regexp(false); err != nil {
+		return fmt.Errorf("invalid regex %s: %s", lp.Regex, err)
+	}
+
+	return nil
+}
+
+func (lp LabelPattern) Match(label string, fold bool) bool {
+	if lp.Glob != "" {
+		glob := lp.Glob
+		if fold {
+			glob, label = strings.ToLower(glob), strings.ToLower(label)
+		}
+
+		ok, err := path.Match(glob, label)
+		return err == nil && ok
+	}
+
+	expr, err := lp.regexp(fold)
+	return err == nil && expr.MatchString(label)
+}
@@ -0,0 +1,98 @@
+package config
+
+import "testing"
+
+func TestLabelPatternMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern LabelPattern
+		label   string
+		fold    bool
+		want    bool
+	}{
+		{
+			name:    "glob",
+			pattern: LabelPattern{Glob: "size-*"},
+			label:   "size-xl",
+			want:    true,
+		},
+		{
+			name:    "glob is case sensitive",
+			pattern: LabelPattern{Glob: "size-*"},
+			label:   "Size-XL",
+		},
+		{
+			name:    "glob folds case",
+			pattern: LabelPattern{Glob: "size-*"},
+			label:   "Size-XL",
+			fold:    true,
+			want:    true,
+		},
+		{
+			name:    "catch-all glob",
+			pattern: LabelPattern{Glob: "*"},
+			label:   "anything",
+			want:    true,
+		},
+		{
+			name:    "regex",
+			pattern: LabelPattern{Regex: "gpu-[0-9]+"},
+			label:   "gpu-2",
+			want:    true,
+		},
+		{
+			name:    "regex is anchored",
+			pattern: LabelPattern{Regex: "gpu"},
+			label:   "gpu-2",
+		},
+		{
+			name:    "regex alternation is anchored",
+			pattern: LabelPattern{Regex: "small|large"},
+			label:   "x-large",
+		},
+		{
+			name:    "regex folds case",
+			pattern: LabelPattern{Regex: "gpu-[0-9]+"},
+			label:   "GPU-2",
+			fold:    true,
+			want:    true,
+		},
+		{
+			name:    "invalid regex",
+			pattern: LabelPattern{Regex: "gpu-("},
+			label:   "gpu-(",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.pattern.Match(tt.label, tt.fold); got != tt.want {
+				t.Errorf("Match(%q, %v) = %v, want %v", tt.label, tt.fold, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelPatternListMatch(t *testing.T) {
+	patterns := LabelPatternList{{Glob: "size-*"}, {Regex: "size-[a-z]+"}, {Glob: "*"}}
+
+	tests := []struct {
+		label string
+		want  int
+	}{
+		{label: "size-xl", want: 0},
+		{label: "gpu", want: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.label, func(t *testing.T) {
+			if got := patterns.Match(tt.label, false); got != tt.want {
+				t.Errorf("Match(%q) = %d, want %d", tt.label, got, tt.want)
+			}
+		})
+	}
+
+	if got := (LabelPatternList{{Glob: "size-*"}}).Match("gpu", false); got != -1 {
+		t.Errorf("Match(%q) = %d, want -1", "gpu", got)
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+)
+
+// name of the container the runner runs in, templates may not remove it
+const RunnerContainerName = "runner"
+
+// a partial pod template merged over the generated one with strategic merge
+// patch semantics, e.g. containers and volumes are merged by name
+type PodTemplate map[string]any
+
+// merges the template over base
+func (t PodTemplate) Apply(base corev1.PodTemplateSpec) (corev1.PodTemplateSpec, error) {
+	if len(t) < 1 {
+		return base, nil
+	}
+
+	original, err := json.Marshal(base)
+	if err != nil {
+		return base, fmt.Errorf("failed to marshal pod template: %s", err)
+	}
+
+	patch, err := json.Marshal(t)
+	if err != nil {
+		return base, fmt.Errorf("failed to marshal pod template patch: %s", err)
+	}
+
+	merged, err := strategicpatch.StrategicMergePatch(original, patch, corev1.PodTemplateSpec{})
+	if err != nil {
+		return base, fmt.Errorf("failed to merge pod template: %s", err)
+	}
+
+	var result corev1.PodTemplateSpec
+	if err := json.Unmarshal(merged, &result); err != nil {
+		return base, fmt.Errorf("failed to unmarshal merged pod template: %s", err)
+	}
+
+	return result, nil
+}
+
+func (t PodTemplate) Validate() error {
+	base := corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: RunnerContainerName}},
+		},
+	}
+
+	merged, err := t.Apply(base)
+	if err != nil {
+		return err
+	}
+
+	for _, container := range merged.Spec.Containers {
+		if container.Name == RunnerContainerName {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("must not remove the %s container", RunnerContainerName)
+}
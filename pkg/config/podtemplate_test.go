@@ -0,0 +1,185 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func parsePodTemplate(t *testing.T, raw string) PodTemplate {
+	t.Helper()
+
+	var template PodTemplate
+	if err := yaml.Unmarshal([]byte(raw), &template); err != nil {
+		t.Fatalf("failed to parse pod template: %s", err)
+	}
+
+	return template
+}
+
+func TestPodTemplateApply(t *testing.T) {
+	base := corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			ServiceAccountName: "runner",
+			Containers: []corev1.Container{{
+				Name:  RunnerContainerName,
+				Image: "runner:latest",
+				Env:   []corev1.EnvVar{{Name: "RUNNER_EPHEMERAL", Value: "true"}},
+			}},
+			Volumes: []corev1.Volume{{Name: "work"}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		template string
+		check    func(t *testing.T, got corev1.PodTemplateSpec)
+	}{
+		{
+			name:     "empty template",
+			template: `{}`,
+			check: func(t *testing.T, got corev1.PodTemplateSpec) {
+				if !reflect.DeepEqual(got, base) {
+					t.Errorf("Apply() = %+v, want base unchanged", got)
+				}
+			},
+		},
+		{
+			name: "merges containers by name",
+			template: `
+spec:
+  containers:
+    - name: runner
+      env:
+        - name: EXTRA
+          value: "1"
+    - name: proxy
+      image: proxy:latest
+`,
+			check: func(t *testing.T, got corev1.PodTemplateSpec) {
+				if len(got.Spec.Containers) != 2 {
+					t.Fatalf("Apply() containers = %+v, want runner and proxy", got.Spec.Containers)
+				}
+
+				runner := got.Spec.Containers[0]
+				if runner.Name != RunnerContainerName || runner.Image != "runner:latest" {
+					t.Errorf("Apply() runner = %+v, want image kept", runner)
+				}
+
+				env := map[string]string{}
+				for _, e := range runner.Env {
+					env[e.Name] = e.Value
+				}
+
+				if env["RUNNER_EPHEMERAL"] != "true" || env["EXTRA"] != "1" {
+					t.Errorf("Apply() runner env = %+v, want both variables", runner.Env)
+				}
+			},
+		},
+		{
+			name: "overrides scalar fields",
+			template: `
+metadata:
+  annotations:
+    example.com/team: ci
+spec:
+  serviceAccountName: builder
+`,
+			check: func(t *testing.T, got corev1.PodTemplateSpec) {
+				if got.Spec.ServiceAccountName != "builder" {
+					t.Errorf("Apply() serviceAccountName = %s, want builder", got.Spec.ServiceAccountName)
+				}
+
+				if got.Annotations["example.com/team"] != "ci" {
+					t.Errorf("Apply() annotations = %v, want example.com/team", got.Annotations)
+				}
+			},
+		},
+		{
+			name: "deletes volumes by name",
+			template: `
+spec:
+  volumes:
+    - name: work
+      $patch: delete
+`,
+			check: func(t *testing.T, got corev1.PodTemplateSpec) {
+				if len(got.Spec.Volumes) != 0 {
+					t.Errorf("Apply() volumes = %+v, want none", got.Spec.Volumes)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePodTemplate(t, tt.template).Apply(base)
+			if err != nil {
+				t.Fatalf("Apply() error = %v", err)
+			}
+
+			tt.check(t, got)
+		})
+	}
+}
+
+func TestPodTemplateValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		wantErr  bool
+	}{
+		{
+			name:     "empty template",
+			template: `{}`,
+		},
+		{
+			name: "adds a container",
+			template: `
+spec:
+  containers:
+    - name: proxy
+      image: proxy:latest
+`,
+		},
+		{
+			name: "deletes the runner container",
+			template: `
+spec:
+  containers:
+    - name: runner
+      $patch: delete
+`,
+			wantErr: true,
+		},
+		{
+			name: "replaces containers without the runner",
+			template: `
+spec:
+  containers:
+    - name: proxy
+      image: proxy:latest
+    - $patch: replace
+`,
+			wantErr: true,
+		},
+		{
+			name: "wrong type",
+			template: `
+spec:
+  containers: runner
+`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := parsePodTemplate(t, tt.template).Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
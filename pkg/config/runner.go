@@ -1,10 +1,18 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/axatol/actions-job-dispatcher/pkg/util"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 type RunnerConfigList []RunnerConfig
@@ -35,24 +43,78 @@ func (rcl RunnerConfigList) Strs() []string {
 type RunnerConfig struct {
 	// github
 
-	Labels Labels `yaml:"labels" json:"labels,omitempty"`
-	Scope  Scope  `yaml:"scope"  json:"scope,omitempty"`
+	Labels         Labels           `yaml:"labels"          json:"labels,omitempty"`
+	LabelPatterns  LabelPatternList `yaml:"label_patterns"  json:"label_patterns,omitempty"`
+	LabelMatch     LabelMatchMode   `yaml:"label_match"     json:"label_match,omitempty"`
+	ImplicitLabels Labels           `yaml:"implicit_labels" json:"implicit_labels,omitempty"`
+	Scope          Scope            `yaml:"scope"           json:"scope,omitempty"`
+
+	// labels matched by patterns for the workflow job being dispatched
+	MatchedLabels Labels `yaml:"-" json:"matched_labels,omitempty"`
 
 	// scheduler
 
-	MaxReplicas int `yaml:"max_replicas" json:"max_replicas,omitempty"`
+	MaxReplicas int               `yaml:"max_replicas" json:"max_replicas,omitempty"`
+	MinIdle     int               `yaml:"min_idle"     json:"min_idle,omitempty"`
+	Schedules   ScalingWindowList `yaml:"schedules"    json:"schedules,omitempty"`
 
 	// kubernetes
 
 	ServiceAccountName string          `yaml:"service_account_name" json:"service_account_name,omitempty"`
 	Image              string          `yaml:"image"                json:"image,omitempty"`
 	Resources          RunnerResources `yaml:"resources"            json:"resources,omitempty"`
+	DockerMode         DockerMode      `yaml:"docker_mode"          json:"docker_mode,omitempty"`
+	DockerImage        string          `yaml:"docker_image"         json:"docker_image,omitempty"`
+	DockerResources    RunnerResources `yaml:"docker_resources"     json:"docker_resources,omitempty"`
+	PodTemplate        PodTemplate     `yaml:"pod_template"         json:"pod_template,omitempty"`
+	Caches             CacheVolumeList `yaml:"caches"               json:"caches,omitempty"`
+	FSGroup            *int64          `yaml:"fs_group"             json:"fs_group,omitempty"`
+
+	// scheduling
+
+	NodeSelector              NodeSelector              `yaml:"node_selector"                json:"node_selector,omitempty"`
+	DisableLabelNodeSelector  bool                      `yaml:"disable_label_node_selector"  json:"disable_label_node_selector,omitempty"`
+	Tolerations               Tolerations               `yaml:"tolerations"                  json:"tolerations,omitempty"`
+	Affinity                  *Affinity                 `yaml:"affinity"                     json:"affinity,omitempty"`
+	TopologySpreadConstraints TopologySpreadConstraints `yaml:"topology_spread_constraints"  json:"topology_spread_constraints,omitempty"`
+	PriorityClassName         string                    `yaml:"priority_class_name"          json:"priority_class_name,omitempty"`
+	RuntimeClassName          string                    `yaml:"runtime_class_name"           json:"runtime_class_name,omitempty"`
 }
 
 func (c RunnerConfig) String() string {
-	return fmt.Sprintf("%s:%s", c.Scope.String(), strings.Join(c.Labels, "+"))
+	labels := append(append([]string{}, c.Labels...), c.LabelPatterns.Strs()...)
+	return fmt.Sprintf("%s:%s", c.Scope.String(), strings.Join(labels, "+"))
+}
+
+// labels the runner registers with, static labels followed by any matched
+func (c RunnerConfig) RegisteredLabels() Labels {
+	results := Labels{}
+	seen := util.NewSet()
+	for _, label := range append(append(Labels{}, c.Labels...), c.MatchedLabels...) {
+		if !seen.Has(label) {
+			seen.Add(label)
+			results = append(results, label)
+		}
+	}
+
+	return results
+}
+
+// identifies the runner config in labels and lookups, unlike the slug it is
+// unique and fits in a label value
+func (c RunnerConfig) ID() string {
+	identity, _ := json.Marshal([]any{
+		c.Scope.IsOrg,
+		c.Scope.String(),
+		append([]string{}, c.Labels...),
+		append([]string{}, c.LabelPatterns.Strs()...),
+	})
+
+	sum := sha256.Sum256(identity)
+	return hex.EncodeToString(sum[:8])
 }
 
+// readable but lossy, use ID to tell runner configs apart
 func (c RunnerConfig) Slug() string {
 	slug := c.String()
 	slug = strings.ReplaceAll(slug, "/", "_") // repo delim
@@ -62,8 +124,18 @@ func (c RunnerConfig) Slug() string {
 }
 
 func (c RunnerConfig) Validate() error {
-	if err := c.Labels.Validate(); err != nil {
-		return fmt.Errorf("invalid labels: %s", err)
+	if len(c.LabelPatterns) < 1 {
+		if err := c.Labels.Validate(); err != nil {
+			return fmt.Errorf("invalid labels: %s", err)
+		}
+	}
+
+	if err := c.LabelPatterns.Validate(); err != nil {
+		return fmt.Errorf("invalid label_patterns: %s", err)
+	}
+
+	if err := c.LabelMatch.Validate(); err != nil {
+		return fmt.Errorf("invalid label_match: %s", err)
 	}
 
 	if err := c.Scope.Validate(); err != nil {
@@ -74,9 +146,205 @@ func (c RunnerConfig) Validate() error {
 		return fmt.Errorf("invalid resources: %s", err)
 	}
 
+	if err := c.DockerMode.Validate(); err != nil {
+		return fmt.Errorf("invalid docker_mode: %s", err)
+	}
+
+	if err := c.DockerResources.Validate(); err != nil {
+		return fmt.Errorf("invalid docker_resources: %s", err)
+	}
+
+	if err := c.Caches.Validate(); err != nil {
+		return fmt.Errorf("invalid caches: %s", err)
+	}
+
+	if c.DockerMode == DockerNone && c.Caches.At(DockerDataPath) != nil {
+		return fmt.Errorf("invalid caches: docker_mode %s does not run dockerd", DockerNone)
+	}
+
+	if c.FSGroup != nil && *c.FSGroup < 0 {
+		return fmt.Errorf("invalid fs_group: must not be negative, got %d", *c.FSGroup)
+	}
+
+	if err := c.NodeSelector.Validate(); err != nil {
+		return fmt.Errorf("invalid node_selector: %s", err)
+	}
+
+	if _, err := c.PodNodeSelector(); err != nil {
+		return fmt.Errorf("invalid labels: %s", err)
+	}
+
+	if err := c.Tolerations.Validate(); err != nil {
+		return fmt.Errorf("invalid tolerations: %s", err)
+	}
+
+	if err := c.Affinity.Validate(); err != nil {
+		return fmt.Errorf("invalid affinity: %s", err)
+	}
+
+	if err := c.TopologySpreadConstraints.Validate(); err != nil {
+		return fmt.Errorf("invalid topology_spread_constraints: %s", err)
+	}
+
+	if err := validateClassName(c.PriorityClassName); err != nil {
+		return fmt.Errorf("invalid priority_class_name: %s", err)
+	}
+
+	if err := validateClassName(c.RuntimeClassName); err != nil {
+		return fmt.Errorf("invalid runtime_class_name: %s", err)
+	}
+
+	if err := c.PodTemplate.Validate(); err != nil {
+		return fmt.Errorf("invalid pod_template: %s", err)
+	}
+
+	// previously optional and unlimited on the webhook path, guessing a limit
+	// would silently change throughput
+	if c.MaxReplicas == 0 {
+		return fmt.Errorf("max_replicas is required, set it to the most runners this config may run at once")
+	}
+
+	if c.MaxReplicas < 1 {
+		return fmt.Errorf("max_replicas must be at least 1, got %d", c.MaxReplicas)
+	}
+
+	if c.MinIdle < 0 || c.MinIdle > c.MaxReplicas {
+		return fmt.Errorf("min_idle must be between 0 and max_replicas (%d), got %d", c.MaxReplicas, c.MinIdle)
+	}
+
+	if err := c.Schedules.Validate(); err != nil {
+		return fmt.Errorf("invalid schedules: %s", err)
+	}
+
 	return nil
 }
 
+// the runner config with the scaling window in effect at the given time applied
+func (c RunnerConfig) At(t time.Time) RunnerConfig {
+	window := c.Schedules.ActiveAt(t)
+	if window == nil {
+		return c
+	}
+
+	if window.MinIdle != nil {
+		c.MinIdle = *window.MinIdle
+	}
+
+	if window.MaxReplicas != nil {
+		c.MaxReplicas = *window.MaxReplicas
+	}
+
+	c.MinIdle = util.MinInt(c.MinIdle, c.MaxReplicas)
+	return c
+}
+
+// labels matched by patterns, how many labels the runner carries beyond those
+// requested, and false if the runner cannot serve the requested labels
+func (c RunnerConfig) MatchLabels(requested []string) (Labels, int, bool) {
+	fold := c.LabelMatch == LabelMatchGithub
+	normalise := func(label string) string {
+		if fold {
+			return strings.ToLower(label)
+		}
+
+		return label
+	}
+
+	available := util.NewSet()
+	for _, label := range c.Labels {
+		available.Add(normalise(label))
+	}
+
+	if fold {
+		implicit := c.ImplicitLabels
+		if implicit == nil {
+			implicit = DefaultImplicitLabels
+		}
+
+		for _, label := range implicit {
+			available.Add(normalise(label))
+		}
+	}
+
+	found := util.NewSet()
+	usedPatterns := map[int]bool{}
+	matched := Labels{}
+	for _, label := range requested {
+		if available.Has(normalise(label)) {
+			found.Add(normalise(label))
+			continue
+		}
+
+		i := c.LabelPatterns.Match(label, fold)
+		if i < 0 {
+			return nil, 0, false
+		}
+
+		usedPatterns[i] = true
+		if !util.NewSet(matched...).Has(label) {
+			matched = append(matched, label)
+		}
+	}
+
+	sort.Strings(matched)
+
+	if fold {
+		return matched, len(available) - len(found), true
+	}
+
+	// exact matching requires every static label and pattern to be used
+	if len(found) != len(available) || len(usedPatterns) != len(c.LabelPatterns) {
+		return nil, 0, false
+	}
+
+	return matched, 0, true
+}
+
+type LabelMatchMode string
+
+const (
+	// job labels must equal the runner labels
+	LabelMatchExact LabelMatchMode = "exact"
+	// job labels must be a case-insensitive subset of the runner labels and
+	// implicit labels, as github routes jobs
+	LabelMatchGithub LabelMatchMode = "github"
+)
+
+// labels every self-hosted runner registers with unless overridden
+var DefaultImplicitLabels = Labels{"self-hosted", "linux", "x64"}
+
+func (m LabelMatchMode) Validate() error {
+	switch m {
+	case "", LabelMatchExact, LabelMatchGithub:
+		return nil
+	}
+
+	return fmt.Errorf("must be one of [%s, %s], got %s", LabelMatchExact, LabelMatchGithub, m)
+}
+
+type DockerMode string
+
+const (
+	// dockerd runs inside a privileged runner container
+	DockerInRunner DockerMode = "in-runner"
+	// dockerd runs in a privileged docker:dind sidecar, reached over tls
+	DockerSidecar DockerMode = "dind-sidecar"
+	// no docker daemon is available to workflow jobs
+	DockerNone DockerMode = "none"
+)
+
+// image of the dind sidecar unless overridden
+const DefaultDockerImage = "docker:dind"
+
+func (m DockerMode) Validate() error {
+	switch m {
+	case "", DockerInRunner, DockerSidecar, DockerNone:
+		return nil
+	}
+
+	return fmt.Errorf("must be one of [%s, %s, %s], got %s", DockerInRunner, DockerSidecar, DockerNone, m)
+}
+
 type Labels []string
 
 func (rl Labels) String() string {
@@ -101,29 +369,120 @@ func (rl Labels) Has(search string) bool {
 	return false
 }
 
+// empty quantities are left unset
 type RunnerResources struct {
-	CPULimit      string `yaml:"cpu_limit"      json:"cpu_limit"`
-	MemoryLimit   string `yaml:"memory_limit"   json:"memory_limit"`
-	CPURequest    string `yaml:"cpu_request"    json:"cpu_request"`
-	MemoryRequest string `yaml:"memory_request" json:"memory_request"`
+	CPULimit                string `yaml:"cpu_limit"                 json:"cpu_limit,omitempty"`
+	MemoryLimit             string `yaml:"memory_limit"              json:"memory_limit,omitempty"`
+	EphemeralStorageLimit   string `yaml:"ephemeral_storage_limit"   json:"ephemeral_storage_limit,omitempty"`
+	CPURequest              string `yaml:"cpu_request"               json:"cpu_request,omitempty"`
+	MemoryRequest           string `yaml:"memory_request"            json:"memory_request,omitempty"`
+	EphemeralStorageRequest string `yaml:"ephemeral_storage_request" json:"ephemeral_storage_request,omitempty"`
+
+	// any other resources by name, e.g. nvidia.com/gpu
+	Limits   map[string]string `yaml:"limits"   json:"limits,omitempty"`
+	Requests map[string]string `yaml:"requests" json:"requests,omitempty"`
 }
 
 func (rr RunnerResources) Validate() error {
-	if _, err := resource.ParseQuantity(rr.CPULimit); err != nil {
-		return fmt.Errorf("invalid cpu limit: %s", err)
+	requirements, err := rr.Requirements()
+	if err != nil {
+		return err
+	}
+
+	for name, request := range requirements.Requests {
+		limit, ok := requirements.Limits[name]
+		if !ok {
+			// the api server rejects these without a limit
+			if !overcommittable(name) {
+				return fmt.Errorf("%s request %s requires a limit", name, request.String())
+			}
+
+			continue
+		}
+
+		if !overcommittable(name) && request.Cmp(limit) != 0 {
+			return fmt.Errorf("%s request %s must equal limit %s", name, request.String(), limit.String())
+		}
+
+		if request.Cmp(limit) > 0 {
+			return fmt.Errorf("%s request %s must not exceed limit %s", name, request.String(), limit.String())
+		}
 	}
 
-	if _, err := resource.ParseQuantity(rr.MemoryLimit); err != nil {
-		return fmt.Errorf("invalid memory limit: %s", err)
+	return nil
+}
+
+// kubernetes does not overcommit extended resources or hugepages, their
+// requests must equal their limits
+func overcommittable(name corev1.ResourceName) bool {
+	if strings.HasPrefix(string(name), corev1.ResourceHugePagesPrefix) {
+		return false
 	}
 
-	if _, err := resource.ParseQuantity(rr.CPURequest); err != nil {
-		return fmt.Errorf("invalid cpu request: %s", err)
+	return !strings.Contains(string(name), "/") || strings.HasPrefix(string(name), "kubernetes.io/")
+}
+
+func (rr RunnerResources) Requirements() (corev1.ResourceRequirements, error) {
+	limits, err := resourceList("limit", rr.Limits, map[corev1.ResourceName]string{
+		corev1.ResourceCPU:              rr.CPULimit,
+		corev1.ResourceMemory:           rr.MemoryLimit,
+		corev1.ResourceEphemeralStorage: rr.EphemeralStorageLimit,
+	})
+	if err != nil {
+		return corev1.ResourceRequirements{}, err
 	}
 
-	if _, err := resource.ParseQuantity(rr.MemoryRequest); err != nil {
-		return fmt.Errorf("invalid memory request: %s", err)
+	requests, err := resourceList("request", rr.Requests, map[corev1.ResourceName]string{
+		corev1.ResourceCPU:              rr.CPURequest,
+		corev1.ResourceMemory:           rr.MemoryRequest,
+		corev1.ResourceEphemeralStorage: rr.EphemeralStorageRequest,
+	})
+	if err != nil {
+		return corev1.ResourceRequirements{}, err
 	}
 
-	return nil
+	return corev1.ResourceRequirements{Limits: limits, Requests: requests}, nil
+}
+
+func resourceList(kind string, extra map[string]string, named map[corev1.ResourceName]string) (corev1.ResourceList, error) {
+	list := corev1.ResourceList{}
+	for name, value := range named {
+		if value == "" {
+			continue
+		}
+
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %s: %s", name, kind, err)
+		}
+
+		list[name] = quantity
+	}
+
+	for name, value := range extra {
+		if value == "" {
+			continue
+		}
+
+		if errs := validation.IsQualifiedName(name); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid resource name %s: %s", name, strings.Join(errs, ", "))
+		}
+
+		if _, ok := list[corev1.ResourceName(name)]; ok {
+			return nil, fmt.Errorf("%s %s is set twice", name, kind)
+		}
+
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %s: %s", name, kind, err)
+		}
+
+		list[corev1.ResourceName(name)] = quantity
+	}
+
+	if len(list) < 1 {
+		return nil, nil
+	}
+
+	return list, nil
 }
@@ -0,0 +1,323 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestRunnerResourcesRequirements(t *testing.T) {
+	tests := []struct {
+		name         string
+		resources    RunnerResources
+		wantLimits   map[corev1.ResourceName]string
+		wantRequests map[corev1.ResourceName]string
+		wantErr      bool
+	}{
+		{
+			name:      "empty quantities are unset",
+			resources: RunnerResources{},
+		},
+		{
+			name: "named quantities",
+			resources: RunnerResources{
+				CPULimit:                "2",
+				MemoryLimit:             "4Gi",
+				EphemeralStorageLimit:   "20Gi",
+				CPURequest:              "500m",
+				MemoryRequest:           "",
+				EphemeralStorageRequest: "10Gi",
+			},
+			wantLimits: map[corev1.ResourceName]string{
+				corev1.ResourceCPU:              "2",
+				corev1.ResourceMemory:           "4Gi",
+				corev1.ResourceEphemeralStorage: "20Gi",
+			},
+			wantRequests: map[corev1.ResourceName]string{
+				corev1.ResourceCPU:              "500m",
+				corev1.ResourceEphemeralStorage: "10Gi",
+			},
+		},
+		{
+			name: "extended resources",
+			resources: RunnerResources{
+				Limits:   map[string]string{"nvidia.com/gpu": "1", "example.com/empty": ""},
+				Requests: map[string]string{"nvidia.com/gpu": "1"},
+			},
+			wantLimits:   map[corev1.ResourceName]string{"nvidia.com/gpu": "1"},
+			wantRequests: map[corev1.ResourceName]string{"nvidia.com/gpu": "1"},
+		},
+		{
+			name:      "invalid quantity",
+			resources: RunnerResources{CPULimit: "two"},
+			wantErr:   true,
+		},
+		{
+			name:      "invalid resource name",
+			resources: RunnerResources{Limits: map[string]string{"bad name": "1"}},
+			wantErr:   true,
+		},
+		{
+			name:      "set twice",
+			resources: RunnerResources{CPULimit: "1", Limits: map[string]string{"cpu": "2"}},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.resources.Requirements()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Requirements() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				return
+			}
+
+			assertResourceList(t, "limits", got.Limits, tt.wantLimits)
+			assertResourceList(t, "requests", got.Requests, tt.wantRequests)
+		})
+	}
+}
+
+func assertResourceList(t *testing.T, kind string, got corev1.ResourceList, want map[corev1.ResourceName]string) {
+	t.Helper()
+
+	if len(want) < 1 {
+		if got != nil {
+			t.Errorf("Requirements() %s = %v, want unset", kind, got)
+		}
+
+		return
+	}
+
+	if len(got) != len(want) {
+		t.Errorf("Requirements() %s = %v, want %v", kind, got, want)
+		return
+	}
+
+	for name, value := range want {
+		quantity, ok := got[name]
+		if !ok || quantity.Cmp(resource.MustParse(value)) != 0 {
+			t.Errorf("Requirements() %s %s = %s, want %s", kind, name, quantity.String(), value)
+		}
+	}
+}
+
+func TestRunnerResourcesValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		resources RunnerResources
+		wantErr   bool
+	}{
+		{
+			name:      "empty",
+			resources: RunnerResources{},
+		},
+		{
+			name:      "request below limit",
+			resources: RunnerResources{CPURequest: "500m", CPULimit: "1"},
+		},
+		{
+			name:      "request without limit",
+			resources: RunnerResources{MemoryRequest: "1Gi"},
+		},
+		{
+			name:      "request over limit",
+			resources: RunnerResources{CPURequest: "2", CPULimit: "1"},
+			wantErr:   true,
+		},
+		{
+			name:      "extended resource limit only",
+			resources: RunnerResources{Limits: map[string]string{"nvidia.com/gpu": "1"}},
+		},
+		{
+			name: "extended resource request without limit",
+			resources: RunnerResources{
+				Requests: map[string]string{"nvidia.com/gpu": "1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "extended resource request below limit",
+			resources: RunnerResources{
+				Limits:   map[string]string{"nvidia.com/gpu": "2"},
+				Requests: map[string]string{"nvidia.com/gpu": "1"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "hugepages request equal to limit",
+			resources: RunnerResources{
+				Limits:   map[string]string{"hugepages-2Mi": "100Mi"},
+				Requests: map[string]string{"hugepages-2Mi": "100Mi"},
+			},
+		},
+		{
+			name: "hugepages request below limit",
+			resources: RunnerResources{
+				Limits:   map[string]string{"hugepages-2Mi": "200Mi"},
+				Requests: map[string]string{"hugepages-2Mi": "100Mi"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "hugepages request without limit",
+			resources: RunnerResources{
+				Requests: map[string]string{"hugepages-1Gi": "1Gi"},
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.resources.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunnerConfigMatchLabels(t *testing.T) {
+	tests := []struct {
+		name        string
+		runner      RunnerConfig
+		requested   []string
+		wantMatched Labels
+		wantSurplus int
+		wantOK      bool
+	}{
+		{
+			name:      "exact",
+			runner:    RunnerConfig{Labels: Labels{"self-hosted", "gpu"}},
+			requested: []string{"gpu", "self-hosted"},
+			wantOK:    true,
+		},
+		{
+			name:      "exact requires every label",
+			runner:    RunnerConfig{Labels: Labels{"self-hosted", "gpu"}},
+			requested: []string{"self-hosted"},
+		},
+		{
+			name:      "exact rejects unknown labels",
+			runner:    RunnerConfig{Labels: Labels{"self-hosted"}},
+			requested: []string{"self-hosted", "gpu"},
+		},
+		{
+			name:      "exact is case sensitive",
+			runner:    RunnerConfig{Labels: Labels{"self-hosted", "gpu"}},
+			requested: []string{"self-hosted", "GPU"},
+		},
+		{
+			name:        "github folds case",
+			runner:      RunnerConfig{Labels: Labels{"gpu"}, LabelMatch: LabelMatchGithub},
+			requested:   []string{"Self-Hosted", "GPU"},
+			wantSurplus: 2,
+			wantOK:      true,
+		},
+		{
+			name:        "github serves a subset",
+			runner:      RunnerConfig{Labels: Labels{"gpu", "large"}, LabelMatch: LabelMatchGithub},
+			requested:   []string{"gpu"},
+			wantSurplus: 4,
+			wantOK:      true,
+		},
+		{
+			name:      "github rejects unknown labels",
+			runner:    RunnerConfig{Labels: Labels{"gpu"}, LabelMatch: LabelMatchGithub},
+			requested: []string{"self-hosted", "arm64"},
+		},
+		{
+			name:        "custom implicit labels",
+			runner:      RunnerConfig{Labels: Labels{"gpu"}, LabelMatch: LabelMatchGithub, ImplicitLabels: Labels{"self-hosted", "ARM64"}},
+			requested:   []string{"self-hosted", "arm64", "gpu"},
+			wantSurplus: 0,
+			wantOK:      true,
+		},
+		{
+			name:      "no implicit labels",
+			runner:    RunnerConfig{Labels: Labels{"gpu"}, LabelMatch: LabelMatchGithub, ImplicitLabels: Labels{}},
+			requested: []string{"self-hosted", "gpu"},
+		},
+		{
+			name:      "exact has no implicit labels",
+			runner:    RunnerConfig{Labels: Labels{"gpu"}},
+			requested: []string{"self-hosted", "gpu"},
+		},
+		{
+			name: "pattern",
+			runner: RunnerConfig{
+				Labels:        Labels{"self-hosted"},
+				LabelPatterns: LabelPatternList{{Glob: "size-*"}},
+			},
+			requested:   []string{"self-hosted", "size-xl"},
+			wantMatched: Labels{"size-xl"},
+			wantOK:      true,
+		},
+		{
+			name: "exact requires every pattern",
+			runner: RunnerConfig{
+				Labels:        Labels{"self-hosted"},
+				LabelPatterns: LabelPatternList{{Glob: "size-*"}},
+			},
+			requested: []string{"self-hosted"},
+		},
+		{
+			name: "pattern matches are sorted and deduplicated",
+			runner: RunnerConfig{
+				LabelPatterns: LabelPatternList{{Regex: "[a-z]+-[0-9]+"}},
+			},
+			requested:   []string{"zone-2", "gpu-1", "zone-2"},
+			wantMatched: Labels{"gpu-1", "zone-2"},
+			wantOK:      true,
+		},
+		{
+			name: "github pattern folds case",
+			runner: RunnerConfig{
+				LabelPatterns: LabelPatternList{{Regex: "gpu-[0-9]+"}},
+				LabelMatch:    LabelMatchGithub,
+			},
+			requested:   []string{"self-hosted", "GPU-2"},
+			wantMatched: Labels{"GPU-2"},
+			wantSurplus: 2,
+			wantOK:      true,
+		},
+		{
+			name: "static labels take precedence over patterns",
+			runner: RunnerConfig{
+				Labels:        Labels{"gpu"},
+				LabelPatterns: LabelPatternList{{Glob: "*"}},
+				LabelMatch:    LabelMatchGithub,
+			},
+			requested:   []string{"self-hosted", "gpu", "large"},
+			wantMatched: Labels{"large"},
+			wantSurplus: 2,
+			wantOK:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			matched, surplus, ok := tt.runner.MatchLabels(tt.requested)
+			if ok != tt.wantOK {
+				t.Fatalf("MatchLabels(%v) ok = %v, want %v", tt.requested, ok, tt.wantOK)
+			}
+
+			if !ok {
+				return
+			}
+
+			if len(matched) != len(tt.wantMatched) || (len(matched) > 0 && !reflect.DeepEqual(matched, tt.wantMatched)) {
+				t.Errorf("MatchLabels(%v) matched = %v, want %v", tt.requested, matched, tt.wantMatched)
+			}
+
+			if surplus != tt.wantSurplus {
+				t.Errorf("MatchLabels(%v) surplus = %d, want %d", tt.requested, surplus, tt.wantSurplus)
+			}
+		})
+	}
+}
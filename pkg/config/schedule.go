@@ -0,0 +1,99 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+type ScalingWindowList []ScalingWindow
+
+func (swl ScalingWindowList) Validate() error {
+	for i, window := range swl {
+		if err := window.Validate(); err != nil {
+			return fmt.Errorf("invalid window %d: %s", i, err)
+		}
+	}
+
+	return nil
+}
+
+// the first window in effect at the given time, if any
+func (swl ScalingWindowList) ActiveAt(t time.Time) *ScalingWindow {
+	for _, window := range swl {
+		if window.ActiveAt(t) {
+			return &window
+		}
+	}
+
+	return nil
+}
+
+// overrides replica settings for a duration starting each time the cron
+// expression fires
+type ScalingWindow struct {
+	Name        string `yaml:"name"         json:"name,omitempty"`
+	Cron        string `yaml:"cron"         json:"cron"`
+	Timezone    string `yaml:"timezone"     json:"timezone,omitempty"`
+	Duration    string `yaml:"duration"     json:"duration"`
+	MinIdle     *int   `yaml:"min_idle"     json:"min_idle,omitempty"`
+	MaxReplicas *int   `yaml:"max_replicas" json:"max_replicas,omitempty"`
+}
+
+func (sw ScalingWindow) String() string {
+	if sw.Name != "" {
+		return sw.Name
+	}
+
+	return fmt.Sprintf("%s for %s", sw.Cron, sw.Duration)
+}
+
+func (sw ScalingWindow) schedule() (cron.Schedule, error) {
+	spec := sw.Cron
+	if sw.Timezone != "" {
+		spec = fmt.Sprintf("CRON_TZ=%s %s", sw.Timezone, sw.Cron)
+	}
+
+	return cron.ParseStandard(spec)
+}
+
+func (sw ScalingWindow) Validate() error {
+	if _, err := sw.schedule(); err != nil {
+		return fmt.Errorf("invalid cron: %s", err)
+	}
+
+	duration, err := time.ParseDuration(sw.Duration)
+	if err != nil {
+		return fmt.Errorf("invalid duration: %s", err)
+	}
+
+	if duration <= 0 {
+		return fmt.Errorf("duration must be positive, got %s", sw.Duration)
+	}
+
+	if sw.MinIdle != nil && *sw.MinIdle < 0 {
+		return fmt.Errorf("min_idle must not be negative, got %d", *sw.MinIdle)
+	}
+
+	if sw.MaxReplicas != nil && *sw.MaxReplicas < 0 {
+		return fmt.Errorf("max_replicas must not be negative, got %d", *sw.MaxReplicas)
+	}
+
+	return nil
+}
+
+// whether the schedule fired within one duration of the given time
+func (sw ScalingWindow) ActiveAt(t time.Time) bool {
+	schedule, err := sw.schedule()
+	if err != nil {
+		return false
+	}
+
+	duration, err := time.ParseDuration(sw.Duration)
+	if err != nil {
+		return false
+	}
+
+	return !schedule.Next(t.Add(-duration)).After(t)
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScalingWindowActiveAt(t *testing.T) {
+	// 2024-01-01 is a monday
+	at := func(value string) time.Time {
+		parsed, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			t.Fatalf("failed to parse time: %s", err)
+		}
+
+		return parsed
+	}
+
+	workdays := ScalingWindow{Cron: "0 9 * * 1-5", Duration: "8h"}
+
+	tests := []struct {
+		name   string
+		window ScalingWindow
+		at     time.Time
+		want   bool
+	}{
+		{
+			name:   "before the schedule fires",
+			window: workdays,
+			at:     at("2024-01-01T08:59:00Z"),
+		},
+		{
+			name:   "as the schedule fires",
+			window: workdays,
+			at:     at("2024-01-01T09:00:00Z"),
+			want:   true,
+		},
+		{
+			name:   "within the duration",
+			window: workdays,
+			at:     at("2024-01-01T16:59:00Z"),
+			want:   true,
+		},
+		{
+			name:   "when the duration ends",
+			window: workdays,
+			at:     at("2024-01-01T17:00:00Z"),
+		},
+		{
+			name:   "on a day the schedule does not fire",
+			window: workdays,
+			at:     at("2024-01-06T10:00:00Z"),
+		},
+		{
+			name:   "spanning midnight",
+			window: ScalingWindow{Cron: "0 22 * * *", Duration: "4h"},
+			at:     at("2024-01-02T01:00:00Z"),
+			want:   true,
+		},
+		{
+			name:   "in the window timezone",
+			window: ScalingWindow{Cron: "0 9 * * *", Timezone: "Australia/Sydney", Duration: "1h"},
+			at:     at("2024-01-01T22:30:00Z"),
+			want:   true,
+		},
+		{
+			name:   "outside the window timezone",
+			window: ScalingWindow{Cron: "0 9 * * *", Timezone: "Australia/Sydney", Duration: "1h"},
+			at:     at("2024-01-01T09:30:00Z"),
+		},
+		{
+			name:   "invalid cron",
+			window: ScalingWindow{Cron: "every day", Duration: "1h"},
+			at:     at("2024-01-01T09:30:00Z"),
+		},
+		{
+			name:   "invalid duration",
+			window: ScalingWindow{Cron: "0 9 * * *", Duration: "an hour"},
+			at:     at("2024-01-01T09:30:00Z"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.window.ActiveAt(tt.at); got != tt.want {
+				t.Errorf("ActiveAt(%s) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScalingWindowListActiveAt(t *testing.T) {
+	windows := ScalingWindowList{
+		{Name: "business hours", Cron: "0 9 * * 1-5", Duration: "8h"},
+		{Name: "nightly", Cron: "0 0 * * *", Duration: "12h"},
+	}
+
+	tests := []struct {
+		name string
+		at   time.Time
+		want string
+	}{
+		{name: "first active window wins", at: time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC), want: "business hours"},
+		{name: "later window", at: time.Date(2024, 1, 6, 10, 0, 0, 0, time.UTC), want: "nightly"},
+		{name: "no window", at: time.Date(2024, 1, 6, 18, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := windows.ActiveAt(tt.at)
+			if got == nil {
+				if tt.want != "" {
+					t.Errorf("ActiveAt(%s) = nil, want %s", tt.at, tt.want)
+				}
+
+				return
+			}
+
+			if got.Name != tt.want {
+				t.Errorf("ActiveAt(%s) = %s, want %s", tt.at, got.Name, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// kubernetes types only carry json tags, so decode them through json to keep
+// their field names, e.g. tolerationSeconds
+func decodeThroughJSON(node *yaml.Node, out any) error {
+	var raw any
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(encoded, out)
+}
+
+// runner labels that imply a well-known node label
+var labelNodeSelectors = map[string][2]string{
+	"x64":     {corev1.LabelArchStable, "amd64"},
+	"amd64":   {corev1.LabelArchStable, "amd64"},
+	"arm64":   {corev1.LabelArchStable, "arm64"},
+	"arm":     {corev1.LabelArchStable, "arm"},
+	"linux":   {corev1.LabelOSStable, "linux"},
+	"windows": {corev1.LabelOSStable, "windows"},
+}
+
+// node selector implied by runner labels such as arm64, an error if labels
+// imply different values for the same node label
+func LabelNodeSelector(labels Labels) (map[string]string, error) {
+	selector := map[string]string{}
+	for _, label := range labels {
+		implied, ok := labelNodeSelectors[strings.ToLower(label)]
+		if !ok {
+			continue
+		}
+
+		key, value := implied[0], implied[1]
+		if existing, ok := selector[key]; ok && existing != value {
+			return nil, fmt.Errorf("labels imply both %s=%s and %s=%s", key, existing, key, value)
+		}
+
+		selector[key] = value
+	}
+
+	return selector, nil
+}
+
+type NodeSelector map[string]string
+
+// the node selector for a runner, explicit entries take precedence over those
+// implied by the labels it registers with
+func (c RunnerConfig) PodNodeSelector() (map[string]string, error) {
+	selector := map[string]string{}
+	if !c.DisableLabelNodeSelector {
+		implied, err := LabelNodeSelector(c.RegisteredLabels())
+		if err != nil {
+			return nil, err
+		}
+
+		selector = implied
+	}
+
+	for key, value := range c.NodeSelector {
+		selector[key] = value
+	}
+
+	return selector, nil
+}
+
+func (s NodeSelector) Validate() error {
+	for key, value := range s {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid key %s: %s", key, strings.Join(errs, ", "))
+		}
+
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("invalid value for %s: %s", key, strings.Join(errs, ", "))
+		}
+	}
+
+	return nil
+}
+
+type Tolerations []corev1.Toleration
+
+func (t *Tolerations) UnmarshalYAML(node *yaml.Node) error {
+	return decodeThroughJSON(node, (*[]corev1.Toleration)(t))
+}
+
+func (t Tolerations) Validate() error {
+	for i, toleration := range t {
+		if toleration.Key != "" {
+			if errs := validation.IsQualifiedName(toleration.Key); len(errs) > 0 {
+				return fmt.Errorf("toleration %d: invalid key: %s", i, strings.Join(errs, ", "))
+			}
+		}
+
+		switch toleration.Operator {
+		case corev1.TolerationOpExists:
+			if toleration.Value != "" {
+				return fmt.Errorf("toleration %d: value must be empty when operator is Exists", i)
+			}
+
+		case "", corev1.TolerationOpEqual:
+			if toleration.Key == "" {
+				return fmt.Errorf("toleration %d: operator must be Exists when key is empty", i)
+			}
+
+			if errs := validation.IsValidLabelValue(toleration.Value); len(errs) > 0 {
+				return fmt.Errorf("toleration %d: invalid value: %s", i, strings.Join(errs, ", "))
+			}
+
+		default:
+			return fmt.Errorf("toleration %d: operator must be one of [Exists, Equal], got %s", i, toleration.Operator)
+		}
+
+		switch toleration.Effect {
+		case "", corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+		default:
+			return fmt.Errorf("toleration %d: effect must be one of [NoSchedule, PreferNoSchedule, NoExecute], got %s", i, toleration.Effect)
+		}
+
+		if toleration.TolerationSeconds != nil && toleration.Effect != corev1.TaintEffectNoExecute {
+			return fmt.Errorf("toleration %d: tolerationSeconds requires effect NoExecute", i)
+		}
+	}
+
+	return nil
+}
+
+type Affinity corev1.Affinity
+
+func (a *Affinity) UnmarshalYAML(node *yaml.Node) error {
+	return decodeThroughJSON(node, (*corev1.Affinity)(a))
+}
+
+func (a *Affinity) Validate() error {
+	if a == nil {
+		return nil
+	}
+
+	if node := a.NodeAffinity; node != nil {
+		if required := node.RequiredDuringSchedulingIgnoredDuringExecution; required != nil {
+			if len(required.NodeSelectorTerms) < 1 {
+				return fmt.Errorf("node affinity: must specify nodeSelectorTerms")
+			}
+
+			for _, term := range required.NodeSelectorTerms {
+				if err := validateNodeSelectorTerm(term); err != nil {
+					return fmt.Errorf("node affinity: %s", err)
+				}
+			}
+		}
+
+		for _, preferred := range node.PreferredDuringSchedulingIgnoredDuringExecution {
+			if err := validateWeight(preferred.Weight); err != nil {
+				return fmt.Errorf("node affinity: %s", err)
+			}
+
+			if err := validateNodeSelectorTerm(preferred.Preference); err != nil {
+				return fmt.Errorf("node affinity: %s", err)
+			}
+		}
+	}
+
+	if pod := a.PodAffinity; pod != nil {
+		if err := validatePodAffinityTerms(pod.RequiredDuringSchedulingIgnoredDuringExecution, pod.PreferredDuringSchedulingIgnoredDuringExecution); err != nil {
+			return fmt.Errorf("pod affinity: %s", err)
+		}
+	}
+
+	if pod := a.PodAntiAffinity; pod != nil {
+		if err := validatePodAffinityTerms(pod.RequiredDuringSchedulingIgnoredDuringExecution, pod.PreferredDuringSchedulingIgnoredDuringExecution); err != nil {
+			return fmt.Errorf("pod anti-affinity: %s", err)
+		}
+	}
+
+	return nil
+}
+
+func validateWeight(weight int32) error {
+	if weight < 1 || weight > 100 {
+		return fmt.Errorf("weight must be between 1 and 100, got %d", weight)
+	}
+
+	return nil
+}
+
+func validateNodeSelectorTerm(term corev1.NodeSelectorTerm) error {
+	for _, requirement := range append(append([]corev1.NodeSelectorRequirement{}, term.MatchExpressions...), term.MatchFields...) {
+		switch requirement.Operator {
+		case corev1.NodeSelectorOpIn, corev1.NodeSelectorOpNotIn:
+			if len(requirement.Values) < 1 {
+				return fmt.Errorf("%s: operator %s requires values", requirement.Key, requirement.Operator)
+			}
+
+		case corev1.NodeSelectorOpExists, corev1.NodeSelectorOpDoesNotExist:
+			if len(requirement.Values) > 0 {
+				return fmt.Errorf("%s: operator %s does not take values", requirement.Key, requirement.Operator)
+			}
+
+		case corev1.NodeSelectorOpGt, corev1.NodeSelectorOpLt:
+			if len(requirement.Values) != 1 {
+				return fmt.Errorf("%s: operator %s requires a single value", requirement.Key, requirement.Operator)
+			}
+
+			if _, err := strconv.ParseInt(requirement.Values[0], 10, 64); err != nil {
+				return fmt.Errorf("%s: operator %s requires an integer value", requirement.Key, requirement.Operator)
+			}
+
+		default:
+			return fmt.Errorf("%s: invalid operator %s", requirement.Key, requirement.Operator)
+		}
+	}
+
+	return nil
+}
+
+func validatePodAffinityTerm(term corev1.PodAffinityTerm) error {
+	if term.TopologyKey == "" {
+		return fmt.Errorf("must specify topologyKey")
+	}
+
+	if errs := validation.IsQualifiedName(term.TopologyKey); len(errs) > 0 {
+		return fmt.Errorf("invalid topologyKey: %s", strings.Join(errs, ", "))
+	}
+
+	if _, err := metav1.LabelSelectorAsSelector(term.LabelSelector); err != nil {
+		return fmt.Errorf("invalid labelSelector: %s", err)
+	}
+
+	return nil
+}
+
+func validatePodAffinityTerms(required []corev1.PodAffinityTerm, preferred []corev1.WeightedPodAffinityTerm) error {
+	for _, term := range required {
+		if err := validatePodAffinityTerm(term); err != nil {
+			return err
+		}
+	}
+
+	for _, term := range preferred {
+		if err := validateWeight(term.Weight); err != nil {
+			return err
+		}
+
+		if err := validatePodAffinityTerm(term.PodAffinityTerm); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type TopologySpreadConstraints []corev1.TopologySpreadConstraint
+
+func (t *TopologySpreadConstraints) UnmarshalYAML(node *yaml.Node) error {
+	return decodeThroughJSON(node, (*[]corev1.TopologySpreadConstraint)(t))
+}
+
+func (t TopologySpreadConstraints) Validate() error {
+	for i, constraint := range t {
+		if constraint.MaxSkew < 1 {
+			return fmt.Errorf("constraint %d: maxSkew must be greater than 0", i)
+		}
+
+		if constraint.TopologyKey == "" {
+			return fmt.Errorf("constraint %d: must specify topologyKey", i)
+		}
+
+		switch constraint.WhenUnsatisfiable {
+		case corev1.DoNotSchedule, corev1.ScheduleAnyway:
+		default:
+			return fmt.Errorf("constraint %d: whenUnsatisfiable must be one of [DoNotSchedule, ScheduleAnyway], got %s", i, constraint.WhenUnsatisfiable)
+		}
+
+		if _, err := metav1.LabelSelectorAsSelector(constraint.LabelSelector); err != nil {
+			return fmt.Errorf("constraint %d: invalid labelSelector: %s", i, err)
+		}
+	}
+
+	return nil
+}
+
+// priority and runtime class names are dns subdomains
+func validateClassName(name string) error {
+	if name == "" {
+		return nil
+	}
+
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,361 @@
+package config
+
+import (
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestLabelNodeSelector(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  Labels
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:   "no implied labels",
+			labels: Labels{"self-hosted", "gpu"},
+			want:   map[string]string{},
+		},
+		{
+			name:   "arch and os",
+			labels: Labels{"self-hosted", "Linux", "ARM64"},
+			want:   map[string]string{corev1.LabelOSStable: "linux", corev1.LabelArchStable: "arm64"},
+		},
+		{
+			name:   "aliases agree",
+			labels: Labels{"x64", "amd64"},
+			want:   map[string]string{corev1.LabelArchStable: "amd64"},
+		},
+		{
+			name:    "conflicting arch",
+			labels:  Labels{"x64", "arm64"},
+			wantErr: true,
+		},
+		{
+			name:    "conflicting os",
+			labels:  Labels{"linux", "windows"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := LabelNodeSelector(tt.labels)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LabelNodeSelector() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LabelNodeSelector() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPodNodeSelector(t *testing.T) {
+	tests := []struct {
+		name    string
+		runner  RunnerConfig
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:   "implied by labels",
+			runner: RunnerConfig{Labels: Labels{"arm64"}},
+			want:   map[string]string{corev1.LabelArchStable: "arm64"},
+		},
+		{
+			name:   "implied by matched labels",
+			runner: RunnerConfig{Labels: Labels{"self-hosted"}, MatchedLabels: Labels{"arm64"}},
+			want:   map[string]string{corev1.LabelArchStable: "arm64"},
+		},
+		{
+			name: "explicit entries take precedence",
+			runner: RunnerConfig{
+				Labels:       Labels{"x64"},
+				NodeSelector: NodeSelector{corev1.LabelArchStable: "arm64", "pool": "runners"},
+			},
+			want: map[string]string{corev1.LabelArchStable: "arm64", "pool": "runners"},
+		},
+		{
+			name: "implied selectors disabled",
+			runner: RunnerConfig{
+				Labels:                   Labels{"x64", "arm64"},
+				DisableLabelNodeSelector: true,
+				NodeSelector:             NodeSelector{"pool": "runners"},
+			},
+			want: map[string]string{"pool": "runners"},
+		},
+		{
+			name:    "conflicting labels",
+			runner:  RunnerConfig{Labels: Labels{"x64", "arm64"}},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.runner.PodNodeSelector()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PodNodeSelector() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("PodNodeSelector() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNodeSelectorValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		selector NodeSelector
+		wantErr  bool
+	}{
+		{name: "empty", selector: NodeSelector{}},
+		{name: "valid", selector: NodeSelector{"example.com/pool": "runners"}},
+		{name: "invalid key", selector: NodeSelector{"bad key": "runners"}, wantErr: true},
+		{name: "invalid value", selector: NodeSelector{"pool": "bad value"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.selector.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTolerationsValidate(t *testing.T) {
+	seconds := int64(60)
+	tests := []struct {
+		name        string
+		tolerations Tolerations
+		wantErr     bool
+	}{
+		{
+			name:        "equal",
+			tolerations: Tolerations{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "runners", Effect: corev1.TaintEffectNoSchedule}},
+		},
+		{
+			name:        "operator defaults to equal",
+			tolerations: Tolerations{{Key: "dedicated", Value: "runners"}},
+		},
+		{
+			name:        "exists without key tolerates everything",
+			tolerations: Tolerations{{Operator: corev1.TolerationOpExists}},
+		},
+		{
+			name:        "exists with value",
+			tolerations: Tolerations{{Key: "dedicated", Operator: corev1.TolerationOpExists, Value: "runners"}},
+			wantErr:     true,
+		},
+		{
+			name:        "equal without key",
+			tolerations: Tolerations{{Operator: corev1.TolerationOpEqual, Value: "runners"}},
+			wantErr:     true,
+		},
+		{
+			name:        "invalid operator",
+			tolerations: Tolerations{{Key: "dedicated", Operator: "In"}},
+			wantErr:     true,
+		},
+		{
+			name:        "invalid effect",
+			tolerations: Tolerations{{Key: "dedicated", Value: "runners", Effect: "Evict"}},
+			wantErr:     true,
+		},
+		{
+			name:        "toleration seconds with NoExecute",
+			tolerations: Tolerations{{Key: "dedicated", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute, TolerationSeconds: &seconds}},
+		},
+		{
+			name:        "toleration seconds without NoExecute",
+			tolerations: Tolerations{{Key: "dedicated", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule, TolerationSeconds: &seconds}},
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.tolerations.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTolerationsUnmarshalYAML(t *testing.T) {
+	var tolerations Tolerations
+	raw := `
+- key: dedicated
+  operator: Exists
+  effect: NoExecute
+  tolerationSeconds: 30
+`
+	if err := yaml.Unmarshal([]byte(raw), &tolerations); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	seconds := int64(30)
+	want := Tolerations{{Key: "dedicated", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute, TolerationSeconds: &seconds}}
+	if !reflect.DeepEqual(tolerations, want) {
+		t.Errorf("Unmarshal() = %+v, want %+v", tolerations, want)
+	}
+}
+
+func TestAffinityValidate(t *testing.T) {
+	term := func(requirements ...corev1.NodeSelectorRequirement) corev1.NodeSelectorTerm {
+		return corev1.NodeSelectorTerm{MatchExpressions: requirements}
+	}
+
+	required := func(terms ...corev1.NodeSelectorTerm) *Affinity {
+		return &Affinity{NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: terms},
+		}}
+	}
+
+	podAffinity := func(terms ...corev1.PodAffinityTerm) *Affinity {
+		return &Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: terms,
+		}}
+	}
+
+	tests := []struct {
+		name     string
+		affinity *Affinity
+		wantErr  bool
+	}{
+		{name: "nil", affinity: nil},
+		{
+			name:     "in with values",
+			affinity: required(term(corev1.NodeSelectorRequirement{Key: "pool", Operator: corev1.NodeSelectorOpIn, Values: []string{"runners"}})),
+		},
+		{
+			name:     "no terms",
+			affinity: required(),
+			wantErr:  true,
+		},
+		{
+			name:     "in without values",
+			affinity: required(term(corev1.NodeSelectorRequirement{Key: "pool", Operator: corev1.NodeSelectorOpIn})),
+			wantErr:  true,
+		},
+		{
+			name:     "exists with values",
+			affinity: required(term(corev1.NodeSelectorRequirement{Key: "pool", Operator: corev1.NodeSelectorOpExists, Values: []string{"runners"}})),
+			wantErr:  true,
+		},
+		{
+			name:     "gt with integer",
+			affinity: required(term(corev1.NodeSelectorRequirement{Key: "cores", Operator: corev1.NodeSelectorOpGt, Values: []string{"4"}})),
+		},
+		{
+			name:     "gt with non-integer",
+			affinity: required(term(corev1.NodeSelectorRequirement{Key: "cores", Operator: corev1.NodeSelectorOpGt, Values: []string{"four"}})),
+			wantErr:  true,
+		},
+		{
+			name:     "invalid operator",
+			affinity: required(term(corev1.NodeSelectorRequirement{Key: "pool", Operator: "Equals", Values: []string{"runners"}})),
+			wantErr:  true,
+		},
+		{
+			name: "preferred weight out of range",
+			affinity: &Affinity{NodeAffinity: &corev1.NodeAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{{Weight: 101}},
+			}},
+			wantErr: true,
+		},
+		{
+			name: "pod anti-affinity",
+			affinity: podAffinity(corev1.PodAffinityTerm{
+				TopologyKey:   corev1.LabelHostname,
+				LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "runner"}},
+			}),
+		},
+		{
+			name:     "pod anti-affinity without topology key",
+			affinity: podAffinity(corev1.PodAffinityTerm{}),
+			wantErr:  true,
+		},
+		{
+			name: "pod anti-affinity with invalid selector",
+			affinity: podAffinity(corev1.PodAffinityTerm{
+				TopologyKey: corev1.LabelHostname,
+				LabelSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{
+					{Key: "app", Operator: metav1.LabelSelectorOpIn},
+				}},
+			}),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.affinity.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTopologySpreadConstraintsValidate(t *testing.T) {
+	valid := corev1.TopologySpreadConstraint{
+		MaxSkew:           1,
+		TopologyKey:       corev1.LabelTopologyZone,
+		WhenUnsatisfiable: corev1.ScheduleAnyway,
+	}
+
+	with := func(mutate func(c *corev1.TopologySpreadConstraint)) TopologySpreadConstraints {
+		constraint := valid
+		mutate(&constraint)
+		return TopologySpreadConstraints{constraint}
+	}
+
+	tests := []struct {
+		name        string
+		constraints TopologySpreadConstraints
+		wantErr     bool
+	}{
+		{name: "valid", constraints: TopologySpreadConstraints{valid}},
+		{name: "zero max skew", constraints: with(func(c *corev1.TopologySpreadConstraint) { c.MaxSkew = 0 }), wantErr: true},
+		{name: "no topology key", constraints: with(func(c *corev1.TopologySpreadConstraint) { c.TopologyKey = "" }), wantErr: true},
+		{name: "invalid when unsatisfiable", constraints: with(func(c *corev1.TopologySpreadConstraint) { c.WhenUnsatisfiable = "" }), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.constraints.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateClassName(t *testing.T) {
+	tests := []struct {
+		name    string
+		class   string
+		wantErr bool
+	}{
+		{name: "empty", class: ""},
+		{name: "valid", class: "high-priority"},
+		{name: "uppercase", class: "HighPriority", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateClassName(tt.class); (err != nil) != tt.wantErr {
+				t.Errorf("validateClassName() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/google/go-github/v51/github"
 )
@@ -22,6 +23,10 @@ func (rs Scope) String() string {
 	return fmt.Sprintf("%s/%s", rs.Owner, rs.Repository)
 }
 
+func (rs Scope) Slug() string {
+	return strings.ReplaceAll(rs.String(), "/", "_")
+}
+
 func (rs Scope) Validate() error {
 	if rs.Owner == "" {
 		return fmt.Errorf("must specify owner")
@@ -85,3 +85,102 @@ func (t logFormatValue) Validate() error {
 
 	return fmt.Errorf("format must be one of [%s], got %s", strings.Join(t.Values(), ", "), t)
 }
+
+type modeValue string
+
+const (
+	// workflow jobs are received through the webhook endpoint
+	WebhookMode modeValue = "webhook"
+	// workflow jobs are discovered by polling the github api
+	PollMode modeValue = "poll"
+)
+
+func (v *modeValue) Default() string {
+	return string(WebhookMode)
+}
+
+func (v *modeValue) Set(s string) error {
+	val := modeValue(s)
+	if err := val.Validate(); err != nil {
+		return err
+	}
+
+	*v = val
+	return nil
+}
+
+func (v *modeValue) String() string {
+	if v == nil {
+		return v.Default()
+	}
+
+	return string(*v)
+}
+
+func (t modeValue) Values() []string {
+	return []string{
+		string(WebhookMode),
+		string(PollMode),
+	}
+}
+
+func (t modeValue) Validate() error {
+	for _, v := range t.Values() {
+		if t == modeValue(v) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("mode must be one of [%s], got %s", strings.Join(t.Values(), ", "), t)
+}
+
+type storeValue string
+
+const (
+	// lost on restart
+	MemoryStore storeValue = "memory"
+	// embedded file, survives restarts when kept on a persistent volume
+	BoltStore storeValue = "bolt"
+	// shared between replicas
+	ConfigMapStore storeValue = "configmap"
+)
+
+func (v *storeValue) Default() string {
+	return string(MemoryStore)
+}
+
+func (v *storeValue) Set(s string) error {
+	val := storeValue(s)
+	if err := val.Validate(); err != nil {
+		return err
+	}
+
+	*v = val
+	return nil
+}
+
+func (v *storeValue) String() string {
+	if v == nil {
+		return v.Default()
+	}
+
+	return string(*v)
+}
+
+func (t storeValue) Values() []string {
+	return []string{
+		string(MemoryStore),
+		string(BoltStore),
+		string(ConfigMapStore),
+	}
+}
+
+func (t storeValue) Validate() error {
+	for _, v := range t.Values() {
+		if t == storeValue(v) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("store must be one of [%s], got %s", strings.Join(t.Values(), ", "), t)
+}
@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/axatol/actions-job-dispatcher/pkg/cache"
+	"github.com/axatol/actions-job-dispatcher/pkg/config"
+	"github.com/axatol/actions-job-dispatcher/pkg/gh"
+	"github.com/rs/zerolog/log"
+)
+
+// populates the cache with workflow jobs queued while the dispatcher was not
+// receiving webhooks
+func Backfill(ctx context.Context) error {
+	scopes := map[string]config.Scope{}
+	for _, runner := range config.Runners {
+		scopes[runner.Scope.String()] = runner.Scope
+	}
+
+	for _, scope := range scopes {
+		count, err := backfillScope(ctx, scope)
+		if err != nil {
+			return fmt.Errorf("failed to backfill %s: %s", scope.String(), err)
+		}
+
+		log.Info().
+			Str("scope", scope.String()).
+			Int("backfilled_job_count", count).
+			Msg("backfilled workflow jobs")
+	}
+
+	return nil
+}
+
+func backfillScope(ctx context.Context, scope config.Scope) (int, error) {
+	client, err := gh.GetClient(ctx, scope)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get github client: %s", err)
+	}
+
+	metas, err := client.ListPendingWorkflowJobs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, meta := range metas {
+		if _, err := MatchRunner(meta); err != nil {
+			continue
+		}
+
+		// webhooks are more recent
+		if cache.Get(meta.WorkflowJobID) != nil {
+			continue
+		}
+
+		// only count what was stored, e.g. the configmap refuses non leaders
+		if err := cache.Set(meta); err != nil {
+			continue
+		}
+
+		count += 1
+	}
+
+	return count, nil
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/axatol/actions-job-dispatcher/pkg/gh"
+	"github.com/axatol/actions-job-dispatcher/pkg/k8s"
+	"github.com/google/go-github/v51/github"
+	"github.com/rs/zerolog/log"
+)
+
+// drops outstanding dispatches for a cancelled workflow job, and deletes jobs
+// dispatched for it whose runner has not picked up any work, continuing past
+// jobs that fail to be cleaned up
+func CancelDispatch(ctx context.Context, workflowJobID int64) error {
+	key := fmt.Sprint(workflowJobID)
+	Dequeue(key)
+	removeWaiting(key)
+
+	jobs, err := k8s.ListJobsByWorkflowJob(workflowJobID)
+	if err != nil {
+		return fmt.Errorf("failed to list jobs: %s", err)
+	}
+
+	// registered runners, by scope
+	registrations := map[string]map[string]*github.Runner{}
+	errs := []error{}
+
+	for _, job := range jobs {
+		if k8s.IsJobFinished(job) {
+			continue
+		}
+
+		runner := findRunnerForJob(job)
+		if runner == nil {
+			continue
+		}
+
+		client, err := gh.GetClient(ctx, runner.Scope)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to get github client for %s: %s", runner.Scope.String(), err))
+			continue
+		}
+
+		scope := runner.Scope.String()
+		if _, ok := registrations[scope]; !ok {
+			runners, err := client.ListRunners(ctx)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to list runners for %s: %s", scope, err))
+				continue
+			}
+
+			registrations[scope] = map[string]*github.Runner{}
+			for _, registration := range runners {
+				registrations[scope][registration.GetName()] = registration
+			}
+		}
+
+		log := log.With().
+			Str("job_name", job.Name).
+			Int64("workflow_job_id", workflowJobID).
+			Logger()
+
+		// the runner may have been handed a different workflow job
+		registration, registered := registrations[scope][k8s.RunnerName(job)]
+		if registered && registration.GetBusy() {
+			log.Info().Msg("runner for cancelled workflow job is busy, keeping job")
+			continue
+		}
+
+		if registered {
+			if err := client.RemoveRunner(ctx, registration.GetID()); err != nil {
+				errs = append(errs, fmt.Errorf("failed to remove runner %s: %s", registration.GetName(), err))
+				continue
+			}
+		}
+
+		if err := k8s.DeleteJob(ctx, job.Name); err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete job %s: %s", job.Name, err))
+			continue
+		}
+
+		log.Info().Bool("deregistered", registered).Msg("deleted job for cancelled workflow job")
+	}
+
+	return errors.Join(errs...)
+}
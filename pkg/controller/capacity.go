@@ -0,0 +1,219 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/axatol/actions-job-dispatcher/pkg/cache"
+	"github.com/axatol/actions-job-dispatcher/pkg/config"
+	"github.com/axatol/actions-job-dispatcher/pkg/k8s"
+	"github.com/axatol/actions-job-dispatcher/pkg/util"
+	"github.com/rs/zerolog/log"
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+var (
+	// serialises capacity checks with the dispatches that consume capacity
+	runnerLocks     = map[string]*sync.Mutex{}
+	runnerLocksLock sync.Mutex
+
+	capacityLock sync.Mutex
+	// jobs created but not yet observed by the informer, by runner config id
+	inflight = map[string]util.Set{}
+	// dispatch requests over capacity, by runner config id, oldest first
+	waiting = map[string][]DispatchRequest{}
+	// workflow jobs recently dispatched for, until the informer catches up
+	dispatched = util.NewTTLSet(5*time.Minute, 0)
+)
+
+func lockRunner(runner config.RunnerConfig) func() {
+	runnerLocksLock.Lock()
+	lock, ok := runnerLocks[runner.ID()]
+	if !ok {
+		lock = &sync.Mutex{}
+		runnerLocks[runner.ID()] = lock
+	}
+	runnerLocksLock.Unlock()
+
+	lock.Lock()
+	return lock.Unlock
+}
+
+func markInflight(id, name string) {
+	capacityLock.Lock()
+	defer capacityLock.Unlock()
+
+	if inflight[id] == nil {
+		inflight[id] = util.NewSet()
+	}
+
+	inflight[id].Add(name)
+}
+
+func markObserved(id, name string) {
+	capacityLock.Lock()
+	defer capacityLock.Unlock()
+
+	if set, ok := inflight[id]; ok {
+		set.Del(name)
+	}
+}
+
+// false if the request is for a workflow job that is no longer cached or has
+// been picked up, warm pool requests are always wanted
+func stillQueued(req DispatchRequest) bool {
+	if req.WorkflowJobID < 1 {
+		return true
+	}
+
+	meta := cache.Get(req.WorkflowJobID)
+	return meta != nil && !meta.StartedAt.After(meta.CreatedAt)
+}
+
+// whether a runner job has been dispatched for a workflow job, false for warm
+// pool requests
+func hasRunnerJob(workflowJobID int64) (bool, error) {
+	if workflowJobID < 1 {
+		return false, nil
+	}
+
+	if dispatched.Has(fmt.Sprint(workflowJobID)) {
+		return true, nil
+	}
+
+	jobs, err := k8s.ListJobsByWorkflowJob(workflowJobID)
+	if err != nil {
+		return false, fmt.Errorf("failed to list jobs: %s", err)
+	}
+
+	return len(jobs) > 0, nil
+}
+
+// active jobs including those the informer has not caught up with yet
+func countActive(runner config.RunnerConfig) (int, error) {
+	jobs, err := ListActiveJobs(runner)
+	if err != nil {
+		return 0, err
+	}
+
+	names := util.NewSet()
+	for _, job := range jobs {
+		names.Add(job.Name)
+	}
+
+	capacityLock.Lock()
+	for name := range inflight[runner.ID()] {
+		names.Add(name)
+	}
+	capacityLock.Unlock()
+
+	return len(names), nil
+}
+
+// holds a request until the runner config has capacity
+func hold(req DispatchRequest) {
+	capacityLock.Lock()
+	defer capacityLock.Unlock()
+
+	id := req.Runner.ID()
+	waiting[id] = append(waiting[id], req)
+	sort.SliceStable(waiting[id], func(i, j int) bool {
+		return waiting[id][i].EnqueuedAt.Before(waiting[id][j].EnqueuedAt)
+	})
+}
+
+// drops the oldest requests for runners registered with the given labels,
+// served by runners dispatched by the reconciler
+func discardWaiting(id, labels string, count int) {
+	capacityLock.Lock()
+	defer capacityLock.Unlock()
+
+	remaining := []DispatchRequest{}
+	for _, req := range waiting[id] {
+		if count > 0 && req.Runner.RegisteredLabels().String() == labels {
+			count -= 1
+			continue
+		}
+
+		remaining = append(remaining, req)
+	}
+
+	waiting[id] = remaining
+}
+
+func removeWaiting(key string) {
+	capacityLock.Lock()
+	defer capacityLock.Unlock()
+
+	for id, requests := range waiting {
+		remaining := []DispatchRequest{}
+		for _, req := range requests {
+			if req.Key != key {
+				remaining = append(remaining, req)
+			}
+		}
+
+		waiting[id] = remaining
+	}
+}
+
+func ListWaitingDispatches() []DispatchRequest {
+	capacityLock.Lock()
+	defer capacityLock.Unlock()
+
+	results := []DispatchRequest{}
+	for _, requests := range waiting {
+		results = append(results, requests...)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].EnqueuedAt.Before(results[j].EnqueuedAt)
+	})
+
+	return results
+}
+
+// moves waiting requests back onto the queue while the runner config has
+// capacity, requests for workflow jobs no longer queued are dropped
+func ReleaseWaiting(runner config.RunnerConfig) error {
+	runner = runner.At(time.Now())
+	id := runner.ID()
+
+	active, err := countActive(runner)
+	if err != nil {
+		return err
+	}
+
+	capacityLock.Lock()
+	var released []DispatchRequest
+	for len(waiting[id]) > 0 && active+len(released) < runner.MaxReplicas {
+		req := waiting[id][0]
+		waiting[id] = waiting[id][1:]
+
+		if !stillQueued(req) {
+			continue
+		}
+
+		released = append(released, req)
+	}
+	capacityLock.Unlock()
+
+	for _, req := range released {
+		log.Debug().Str("dispatch_key", req.Key).Msg("releasing waiting dispatch")
+		Enqueue(req)
+	}
+
+	return nil
+}
+
+func findRunnerForJob(job *batchv1.Job) *config.RunnerConfig {
+	for _, runner := range config.Runners {
+		if k8s.IsRunnerJob(job, runner) {
+			return &runner
+		}
+	}
+
+	return nil
+}
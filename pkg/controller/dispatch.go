@@ -4,22 +4,38 @@ import (
 	"context"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/axatol/actions-job-dispatcher/pkg/cache"
 	"github.com/axatol/actions-job-dispatcher/pkg/config"
 	"github.com/axatol/actions-job-dispatcher/pkg/gh"
 	"github.com/axatol/actions-job-dispatcher/pkg/k8s"
-	"github.com/axatol/actions-job-dispatcher/pkg/util"
 	"github.com/google/go-github/v51/github"
 	"github.com/rs/zerolog/log"
+	batchv1 "k8s.io/api/batch/v1"
 )
 
-func Dispatch(ctx context.Context, runner config.RunnerConfig) error {
+// meta is the workflow job the runner is dispatched for, nil for warm pool
+// runners
+func Dispatch(ctx context.Context, runner config.RunnerConfig, meta *cache.WorkflowJobMeta) error {
 	gh, err := gh.GetClient(ctx, runner.Scope)
 	if err != nil {
 		return fmt.Errorf("failed to get github client: %s", err)
 	}
 
 	job := k8s.NewRunnerJob()
+	if meta != nil {
+		cache.UpdateTimeline(meta.WorkflowJobID, func(t *cache.Timeline) {
+			cache.Stamp(&t.DispatchRequestedAt, time.Now())
+		})
+
+		for key, value := range meta.StringMap() {
+			job.AddAnnotation(key, value)
+		}
+
+		job.AddLabel(k8s.WorkflowJobLabelKey, fmt.Sprint(meta.WorkflowJobID))
+		job.AddLabel(k8s.WorkflowRunLabelKey, fmt.Sprint(meta.WorkflowID))
+	}
 
 	if config.DryRun {
 		job.AddEnv("RUNNER_TOKEN", "DRYRUN")
@@ -34,7 +50,10 @@ func Dispatch(ctx context.Context, runner config.RunnerConfig) error {
 		}
 	}
 
-	tmpl := job.Render(runner)
+	tmpl, err := job.Render(runner)
+	if err != nil {
+		return fmt.Errorf("failed to render job: %s", err)
+	}
 
 	if config.DryRun {
 		log.Debug().Any("template", tmpl).Msg("dry run enabled: not dispatching")
@@ -46,6 +65,31 @@ func Dispatch(ctx context.Context, runner config.RunnerConfig) error {
 		return fmt.Errorf("failed to dispatch job: %s", err)
 	}
 
+	// the workflow job may have completed or been cancelled while dispatching
+	if meta != nil && cache.Get(meta.WorkflowJobID) == nil {
+		log.Info().
+			Str("job_name", createdJob.Name).
+			Int64("workflow_job_id", meta.WorkflowJobID).
+			Msg("workflow job finished while dispatching, deleting job")
+
+		// retrying would dispatch again, the idle runner serves another workflow
+		// job or hits its deadline instead
+		if err := k8s.DeleteJob(ctx, createdJob.Name); err != nil {
+			log.Error().Err(err).Str("job_name", createdJob.Name).Msg("failed to delete job for finished workflow job")
+		}
+
+		return nil
+	}
+
+	markInflight(runner.ID(), createdJob.Name)
+
+	if meta != nil {
+		dispatched.Add(fmt.Sprint(meta.WorkflowJobID))
+		cache.UpdateTimeline(meta.WorkflowJobID, func(t *cache.Timeline) {
+			cache.Stamp(&t.JobCreatedAt, createdJob.CreationTimestamp.Time)
+		})
+	}
+
 	log.Info().
 		Str("job_name", createdJob.Name).
 		Msg("dispatched job")
@@ -53,23 +97,58 @@ func Dispatch(ctx context.Context, runner config.RunnerConfig) error {
 	return nil
 }
 
+// the workflow job a runner job was dispatched for, nil for warm pool runners
+func JobWorkflowJob(job *batchv1.Job) *cache.WorkflowJobMeta {
+	if k8s.JobWorkflowJobID(job) < 1 {
+		return nil
+	}
+
+	meta := cache.MetaFromStringMap(k8s.PrefixMapFromLabels(job.Annotations).Extract())
+	return &meta
+}
+
 func SelectRunner(event *github.WorkflowJobEvent) (*config.RunnerConfig, error) {
-	targetRunnerLabels := util.NewSet(event.WorkflowJob.Labels...)
+	return MatchRunner(*cache.WorkflowJobMetaFromEvent(event))
+}
+
+// finds the runner config serving the scope and labels of a workflow job,
+// preferring the config with the fewest surplus labels, then the fewest labels
+// matched by patterns, then config order
+func MatchRunner(meta cache.WorkflowJobMeta) (*config.RunnerConfig, error) {
+	var (
+		best        *config.RunnerConfig
+		bestSurplus int
+	)
+
 	for _, runner := range config.Runners {
-		if runner.Scope.IsOrg && event.Org == nil {
+		if !strings.EqualFold(runner.Scope.Owner, meta.Owner) {
+			continue
+		}
+
+		if runner.Scope.IsOrg && !meta.IsOrg {
 			continue
 		}
 
-		if !runner.Scope.IsOrg && event.Org != nil {
+		if !runner.Scope.IsOrg && !strings.EqualFold(runner.Scope.Repository, meta.Repository) {
 			continue
 		}
 
-		if !targetRunnerLabels.EqualsStrs(runner.Labels) {
+		matched, surplus, ok := runner.MatchLabels(meta.RunnerLabels)
+		if !ok {
 			continue
 		}
 
-		return &runner, nil
+		// a config naming the label beats a catch-all pattern
+		if best == nil || surplus < bestSurplus || (surplus == bestSurplus && len(matched) < len(best.MatchedLabels)) {
+			runner := runner
+			runner.MatchedLabels = matched
+			best, bestSurplus = &runner, surplus
+		}
+	}
+
+	if best == nil {
+		return nil, fmt.Errorf("no matching runner for labels: %s", strings.Join(meta.RunnerLabels, ", "))
 	}
 
-	return nil, fmt.Errorf("no matching runner for labels: %s", strings.Join(event.WorkflowJob.Labels, ", "))
+	return best, nil
 }
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/axatol/actions-job-dispatcher/pkg/cache"
+	"github.com/axatol/actions-job-dispatcher/pkg/config"
+)
+
+func TestMatchRunner(t *testing.T) {
+	org := config.Scope{IsOrg: true, Owner: "acme"}
+	repo := config.Scope{Owner: "acme", Repository: "app"}
+
+	tests := []struct {
+		name    string
+		runners config.RunnerConfigList
+		meta    cache.WorkflowJobMeta
+		want    int
+	}{
+		{
+			name: "fewest surplus labels",
+			runners: config.RunnerConfigList{
+				{Scope: org, Labels: config.Labels{"gpu", "large"}, LabelMatch: config.LabelMatchGithub},
+				{Scope: org, Labels: config.Labels{"gpu"}, LabelMatch: config.LabelMatchGithub},
+			},
+			meta: cache.WorkflowJobMeta{Scope: org, RunnerLabels: []string{"self-hosted", "gpu"}},
+			want: 1,
+		},
+		{
+			name: "config order on ties",
+			runners: config.RunnerConfigList{
+				{Scope: org, Labels: config.Labels{"gpu"}, LabelMatch: config.LabelMatchGithub},
+				{Scope: repo, Labels: config.Labels{"gpu"}, LabelMatch: config.LabelMatchGithub},
+			},
+			meta: cache.WorkflowJobMeta{Scope: config.Scope{IsOrg: true, Owner: "acme", Repository: "app"}, RunnerLabels: []string{"gpu"}},
+			want: 0,
+		},
+		{
+			name: "literal label beats a catch-all pattern",
+			runners: config.RunnerConfigList{
+				{Scope: org, LabelPatterns: config.LabelPatternList{{Glob: "*"}}, LabelMatch: config.LabelMatchGithub},
+				{Scope: org, Labels: config.Labels{"gpu"}, LabelMatch: config.LabelMatchGithub},
+			},
+			meta: cache.WorkflowJobMeta{Scope: org, RunnerLabels: []string{"self-hosted", "gpu"}},
+			want: 1,
+		},
+		{
+			name: "catch-all pattern serves the rest",
+			runners: config.RunnerConfigList{
+				{Scope: org, LabelPatterns: config.LabelPatternList{{Glob: "*"}}, LabelMatch: config.LabelMatchGithub},
+				{Scope: org, Labels: config.Labels{"gpu"}, LabelMatch: config.LabelMatchGithub},
+			},
+			meta: cache.WorkflowJobMeta{Scope: org, RunnerLabels: []string{"self-hosted", "arm64"}},
+			want: 0,
+		},
+		{
+			name: "owner folds case",
+			runners: config.RunnerConfigList{
+				{Scope: org, Labels: config.Labels{"self-hosted"}},
+			},
+			meta: cache.WorkflowJobMeta{Scope: config.Scope{IsOrg: true, Owner: "ACME"}, RunnerLabels: []string{"self-hosted"}},
+			want: 0,
+		},
+		{
+			name: "org runners do not serve repository hooks",
+			runners: config.RunnerConfigList{
+				{Scope: org, Labels: config.Labels{"self-hosted"}},
+			},
+			meta: cache.WorkflowJobMeta{Scope: repo, RunnerLabels: []string{"self-hosted"}},
+			want: -1,
+		},
+		{
+			name: "other repository",
+			runners: config.RunnerConfigList{
+				{Scope: repo, Labels: config.Labels{"self-hosted"}},
+			},
+			meta: cache.WorkflowJobMeta{Scope: config.Scope{Owner: "acme", Repository: "web"}, RunnerLabels: []string{"self-hosted"}},
+			want: -1,
+		},
+		{
+			name: "no matching labels",
+			runners: config.RunnerConfigList{
+				{Scope: org, Labels: config.Labels{"self-hosted"}},
+			},
+			meta: cache.WorkflowJobMeta{Scope: org, RunnerLabels: []string{"self-hosted", "gpu"}},
+			want: -1,
+		},
+	}
+
+	defer func(runners config.RunnerConfigList) { config.Runners = runners }(config.Runners)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Runners = tt.runners
+
+			got, err := MatchRunner(tt.meta)
+			if tt.want < 0 {
+				if err == nil {
+					t.Errorf("MatchRunner() = %s, want error", got.ID())
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("MatchRunner() error = %v", err)
+			}
+
+			if want := tt.runners[tt.want]; got.ID() != want.ID() {
+				t.Errorf("MatchRunner() = %s, want runner %d %s", got.String(), tt.want, want.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"github.com/axatol/actions-job-dispatcher/pkg/cache"
+	"github.com/axatol/actions-job-dispatcher/pkg/k8s"
+	"github.com/rs/zerolog/log"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+)
+
+// buffered so a trigger is never lost while a pass is running
+var trigger = make(chan struct{}, 1)
+
+// requests a reconciliation outside of the regular sync interval
+func Trigger() {
+	select {
+	case trigger <- struct{}{}:
+	default:
+	}
+}
+
+func Triggered() <-chan struct{} {
+	return trigger
+}
+
+// capacity is freed whenever a job finishes or is removed
+func WatchJobs() error {
+	return k8s.OnJobEvent(toolscache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj any) {
+			if job, ok := obj.(*batchv1.Job); ok {
+				log.Debug().Str("job_name", job.Name).Msg("observed job added")
+				if runner := findRunnerForJob(job); runner != nil {
+					markObserved(runner.ID(), job.Name)
+				}
+			}
+		},
+		UpdateFunc: func(oldObj, newObj any) {
+			oldJob, oldOk := oldObj.(*batchv1.Job)
+			newJob, newOk := newObj.(*batchv1.Job)
+			if !oldOk || !newOk {
+				return
+			}
+
+			if !k8s.IsJobFinished(oldJob) && k8s.IsJobFinished(newJob) {
+				log.Debug().Str("job_name", newJob.Name).Msg("observed job finished")
+				capacityFreed(newJob)
+			}
+		},
+		DeleteFunc: func(obj any) {
+			if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+				obj = tombstone.Obj
+			}
+
+			if job, ok := obj.(*batchv1.Job); ok {
+				log.Debug().Str("job_name", job.Name).Msg("observed job deleted")
+				capacityFreed(job)
+			}
+		},
+	})
+}
+
+// records when runner pods are scheduled and start on the workflow job
+// timeline, only the leader writes to the cache
+func WatchPods() error {
+	stamp := func(obj any) {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok || !k8s.IsLeader() {
+			return
+		}
+
+		id := k8s.PodWorkflowJobID(pod)
+		scheduledAt, startedAt := k8s.PodScheduledAt(pod), k8s.RunnerStartedAt(pod)
+		if id < 1 || (scheduledAt.IsZero() && startedAt.IsZero()) {
+			return
+		}
+
+		cache.UpdateTimeline(id, func(t *cache.Timeline) {
+			cache.Stamp(&t.PodScheduledAt, scheduledAt)
+			cache.Stamp(&t.ContainerStartedAt, startedAt)
+		})
+	}
+
+	return k8s.OnPodEvent(toolscache.ResourceEventHandlerFuncs{
+		AddFunc:    stamp,
+		UpdateFunc: func(_, obj any) { stamp(obj) },
+	})
+}
+
+func capacityFreed(job *batchv1.Job) {
+	defer Trigger()
+
+	runner := findRunnerForJob(job)
+	if runner == nil || !k8s.IsLeader() {
+		return
+	}
+
+	markObserved(runner.ID(), job.Name)
+	if err := ReleaseWaiting(*runner); err != nil {
+		log.Error().Err(err).Str("runner", runner.String()).Msg("failed to release waiting dispatches")
+	}
+}
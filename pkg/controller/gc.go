@@ -0,0 +1,131 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/axatol/actions-job-dispatcher/pkg/config"
+	"github.com/axatol/actions-job-dispatcher/pkg/gh"
+	"github.com/axatol/actions-job-dispatcher/pkg/k8s"
+	"github.com/axatol/actions-job-dispatcher/pkg/util"
+	"github.com/rs/zerolog/log"
+)
+
+type RemovedRunner struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Scope     string    `json:"scope"`
+	RemovedAt time.Time `json:"removed_at"`
+}
+
+var (
+	gcLock sync.Mutex
+	// when each offline runner was first seen without a backing job
+	orphanedSince = map[int64]time.Time{}
+	lastRemoved   = []RemovedRunner{}
+)
+
+// runners removed by the most recent collection
+func LastRemovedRunners() []RemovedRunner {
+	gcLock.Lock()
+	defer gcLock.Unlock()
+	return lastRemoved
+}
+
+// deregisters offline runners created by the dispatcher whose job no longer
+// exists, once they have been orphaned for longer than the grace period
+func CollectGarbage(ctx context.Context) ([]RemovedRunner, error) {
+	gcLock.Lock()
+	defer gcLock.Unlock()
+
+	jobs, err := k8s.ListJobs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %s", err)
+	}
+
+	backed := util.NewSet()
+	for _, job := range jobs {
+		if !k8s.IsJobFinished(job) {
+			backed.Add(k8s.RunnerName(job))
+		}
+	}
+
+	// runner name prefixes owned by the dispatcher, grouped by scope
+	scopes := map[string]config.Scope{}
+	prefixes := map[string][]string{}
+	for _, runner := range config.Runners {
+		scopes[runner.Scope.String()] = runner.Scope
+		prefixes[runner.Scope.String()] = append(prefixes[runner.Scope.String()], k8s.RunnerNamePrefix(runner))
+	}
+
+	removed := []RemovedRunner{}
+	seen := map[int64]bool{}
+	for key, scope := range scopes {
+		client, err := gh.GetClient(ctx, scope)
+		if err != nil {
+			return removed, fmt.Errorf("failed to get github client for %s: %s", key, err)
+		}
+
+		runners, err := client.ListRunners(ctx)
+		if err != nil {
+			return removed, err
+		}
+
+		for _, runner := range runners {
+			if !hasAnyPrefix(runner.GetName(), prefixes[key]) {
+				continue
+			}
+
+			if runner.GetStatus() != "offline" || backed.Has(runner.GetName()) {
+				continue
+			}
+
+			seen[runner.GetID()] = true
+			since, ok := orphanedSince[runner.GetID()]
+			if !ok {
+				orphanedSince[runner.GetID()] = time.Now()
+				continue
+			}
+
+			if time.Since(since) < config.GCGracePeriod {
+				continue
+			}
+
+			if err := client.RemoveRunner(ctx, runner.GetID()); err != nil {
+				log.Error().Err(err).Str("runner_name", runner.GetName()).Msg("failed to remove offline runner")
+				continue
+			}
+
+			delete(orphanedSince, runner.GetID())
+			removed = append(removed, RemovedRunner{
+				ID:        runner.GetID(),
+				Name:      runner.GetName(),
+				Scope:     key,
+				RemovedAt: time.Now(),
+			})
+		}
+	}
+
+	// forget runners that came back online or have since disappeared
+	for id := range orphanedSince {
+		if !seen[id] {
+			delete(orphanedSince, id)
+		}
+	}
+
+	lastRemoved = removed
+	return removed, nil
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/axatol/actions-job-dispatcher/pkg/cache"
+	"github.com/axatol/actions-job-dispatcher/pkg/config"
+	"github.com/axatol/actions-job-dispatcher/pkg/gh"
+	"github.com/google/go-github/v51/github"
+	"github.com/rs/zerolog/log"
+)
+
+type polledJob struct {
+	meta   cache.WorkflowJobMeta
+	scope  config.Scope
+	status string
+}
+
+var (
+	pollLock sync.Mutex
+	// last status seen for each workflow job, by id
+	polled = map[int64]polledJob{}
+)
+
+// lists active workflow jobs for each scope and replays their status changes
+// as workflow_job events, used instead of webhooks in poll mode
+func Poll(ctx context.Context) error {
+	pollLock.Lock()
+	defer pollLock.Unlock()
+
+	scopes := map[string]config.Scope{}
+	for _, runner := range config.Runners {
+		scopes[runner.Scope.String()] = runner.Scope
+	}
+
+	for _, scope := range scopes {
+		if err := pollScope(ctx, scope); err != nil {
+			return fmt.Errorf("failed to poll %s: %s", scope.String(), err)
+		}
+	}
+
+	return nil
+}
+
+func pollScope(ctx context.Context, scope config.Scope) error {
+	client, err := gh.GetClient(ctx, scope)
+	if err != nil {
+		return fmt.Errorf("failed to get github client: %s", err)
+	}
+
+	// leave enough requests for registering runners
+	if until, limited := client.RateLimitedUntil(config.PollRateLimitReserve); limited {
+		log.Warn().
+			Str("scope", scope.String()).
+			Time("rate_limit_reset", until).
+			Msg("rate limit reserve reached, skipping poll")
+		return nil
+	}
+
+	jobs, err := client.ListActiveWorkflowJobs(ctx)
+	if err != nil {
+		return err
+	}
+
+	seen := map[int64]bool{}
+	for _, job := range jobs {
+		seen[job.Job.GetID()] = true
+
+		prev, ok := polled[job.Job.GetID()]
+		if ok && prev.status == job.Job.GetStatus() {
+			continue
+		}
+
+		polled[job.Job.GetID()] = polledJob{
+			meta:   *cache.WorkflowJobMetaFromJob(job.Scope, job.Job),
+			scope:  scope,
+			status: job.Job.GetStatus(),
+		}
+
+		acceptPolledEvent(polledEvent(job.Scope, job.Job))
+	}
+
+	// jobs no longer listed have most likely completed
+	for id, prev := range polled {
+		if seen[id] || prev.scope.String() != scope.String() {
+			continue
+		}
+
+		job, err := client.DescribeWorkflowJob(ctx, &prev.meta)
+		if err != nil {
+			log.Warn().Err(err).Int64("workflow_job_id", id).Msg("failed to describe polled workflow job")
+			continue
+		}
+
+		if job.GetStatus() != "completed" {
+			continue
+		}
+
+		delete(polled, id)
+		acceptPolledEvent(polledEvent(prev.meta.Scope, job))
+	}
+
+	return nil
+}
+
+// the parts of a workflow_job webhook payload the dispatcher reads
+func polledEvent(scope config.Scope, job *github.WorkflowJob) *github.WorkflowJobEvent {
+	e := github.WorkflowJobEvent{
+		Action:      github.String(job.GetStatus()),
+		WorkflowJob: job,
+		Repo: &github.Repository{
+			Name:  github.String(scope.Repository),
+			Owner: &github.User{Login: github.String(scope.Owner)},
+		},
+	}
+
+	if scope.IsOrg {
+		e.Org = &github.Organization{Login: github.String(scope.Owner)}
+	}
+
+	return &e
+}
+
+func acceptPolledEvent(e *github.WorkflowJobEvent) {
+	log := log.With().
+		Int64("workflow_job_id", e.GetWorkflowJob().GetID()).
+		Str("workflow_job_status", e.GetWorkflowJob().GetStatus()).
+		Str("workflow_job_html_url", e.GetWorkflowJob().GetHTMLURL()).
+		Logger()
+
+	runner, err := AcceptWorkflowJobEvent(e)
+	if err != nil {
+		log.Debug().Err(err).Msg("ignoring polled workflow job")
+		return
+	}
+
+	log.Debug().Msg("polled workflow job transition")
+	HandleWorkflowJobEvent(e, *runner)
+}
@@ -0,0 +1,247 @@
+package controller
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/axatol/actions-job-dispatcher/pkg/cache"
+	"github.com/axatol/actions-job-dispatcher/pkg/config"
+	"github.com/axatol/actions-job-dispatcher/pkg/k8s"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+)
+
+type DispatchRequest struct {
+	Key           string              `json:"key"`
+	WorkflowJobID int64               `json:"workflow_job_id,omitempty"`
+	Runner        config.RunnerConfig `json:"runner"`
+	EnqueuedAt    time.Time           `json:"enqueued_at"`
+
+	// distinguishes requests enqueued under the same key
+	seq uint64
+}
+
+type FailedDispatch struct {
+	DispatchRequest
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+var (
+	// items are keys into pending, requests themselves are not comparable
+	queue = workqueue.NewNamedRateLimitingQueue(
+		workqueue.NewMaxOfRateLimiter(
+			workqueue.NewItemExponentialFailureRateLimiter(time.Second, 5*time.Minute),
+			&workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(10), 100)},
+		),
+		"dispatch",
+	)
+
+	queueLock sync.Mutex
+	queueSeq  uint64
+	pending   = map[string]DispatchRequest{}
+	failed    = map[string]FailedDispatch{}
+)
+
+// schedules a dispatch to be picked up by a worker
+func Enqueue(req DispatchRequest) {
+	if req.EnqueuedAt.IsZero() {
+		req.EnqueuedAt = time.Now()
+	}
+
+	cache.UpdateTimeline(req.WorkflowJobID, func(t *cache.Timeline) {
+		cache.Stamp(&t.DispatchRequestedAt, req.EnqueuedAt)
+	})
+
+	queueLock.Lock()
+	queueSeq++
+	req.seq = queueSeq
+	pending[req.Key] = req
+	delete(failed, req.Key)
+	queueLock.Unlock()
+
+	queue.Add(req.Key)
+}
+
+// drops a dispatch that has not been picked up yet
+func Dequeue(key string) {
+	queueLock.Lock()
+	defer queueLock.Unlock()
+
+	delete(pending, key)
+	delete(failed, key)
+}
+
+// moves a failed dispatch back onto the queue
+func RetryFailedDispatch(key string) bool {
+	queueLock.Lock()
+	item, ok := failed[key]
+	queueLock.Unlock()
+
+	if ok {
+		Enqueue(item.DispatchRequest)
+	}
+
+	return ok
+}
+
+func ListPendingDispatches() []DispatchRequest {
+	queueLock.Lock()
+	defer queueLock.Unlock()
+
+	results := []DispatchRequest{}
+	for _, item := range pending {
+		results = append(results, item)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].EnqueuedAt.Before(results[j].EnqueuedAt)
+	})
+
+	return results
+}
+
+func ListFailedDispatches() []FailedDispatch {
+	queueLock.Lock()
+	defer queueLock.Unlock()
+
+	results := []FailedDispatch{}
+	for _, item := range failed {
+		results = append(results, item)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].FailedAt.Before(results[j].FailedAt)
+	})
+
+	return results
+}
+
+// runs workers until the context is cancelled
+func StartDispatchWorkers(ctx context.Context, count int) {
+	go func() {
+		<-ctx.Done()
+		queue.ShutDown()
+	}()
+
+	for i := 0; i < count; i++ {
+		go func() {
+			for processNextDispatch(ctx) {
+			}
+		}()
+	}
+}
+
+func processNextDispatch(ctx context.Context) bool {
+	item, shutdown := queue.Get()
+	if shutdown {
+		return false
+	}
+
+	defer queue.Done(item)
+	key := item.(string)
+
+	queueLock.Lock()
+	req, ok := pending[key]
+	queueLock.Unlock()
+
+	if !ok {
+		queue.Forget(item)
+		return true
+	}
+
+	log := log.With().
+		Str("dispatch_key", key).
+		Str("runner", req.Runner.String()).
+		Int("attempt", queue.NumRequeues(item)+1).
+		Logger()
+
+	// leadership may have moved since the request was accepted
+	if !k8s.IsLeader() {
+		log.Warn().Str("leader", k8s.Leader()).Msg("no longer the leader, dropping dispatch")
+		complete(item, req)
+		return true
+	}
+
+	unlock := lockRunner(req.Runner)
+	runner := req.Runner.At(time.Now())
+
+	// the workflow job may have finished or been picked up since
+	if !stillQueued(req) {
+		unlock()
+		log.Info().Int64("workflow_job_id", req.WorkflowJobID).Msg("workflow job is no longer queued, dropping dispatch")
+		complete(item, req)
+		return true
+	}
+
+	// the workflow job may have been dispatched since it was enqueued
+	exists, err := hasRunnerJob(req.WorkflowJobID)
+	if err == nil && exists {
+		unlock()
+		log.Info().Int64("workflow_job_id", req.WorkflowJobID).Msg("runner job already exists, dropping dispatch")
+		complete(item, req)
+		return true
+	}
+
+	var active int
+	if err == nil {
+		active, err = countActive(runner)
+	}
+
+	if err == nil && active >= runner.MaxReplicas {
+		unlock()
+		log.Info().
+			Int("active_job_count", active).
+			Int("max_replicas", runner.MaxReplicas).
+			Msg("runner is at maximum replicas, waiting for capacity")
+		hold(req)
+		complete(item, req)
+		return true
+	}
+
+	if err == nil {
+		err = Dispatch(ctx, runner, cache.Get(req.WorkflowJobID))
+	}
+
+	unlock()
+	if err == nil {
+		complete(item, req)
+		return true
+	}
+
+	if queue.NumRequeues(item) < config.DispatchMaxRetries {
+		log.Warn().Err(err).Msg("dispatch failed, retrying")
+		queue.AddRateLimited(item)
+		return true
+	}
+
+	log.Error().Err(err).Msg("dispatch failed, giving up")
+
+	queueLock.Lock()
+	failed[key] = FailedDispatch{
+		DispatchRequest: req,
+		Error:           err.Error(),
+		Attempts:        queue.NumRequeues(item) + 1,
+		FailedAt:        time.Now(),
+	}
+	queueLock.Unlock()
+
+	complete(item, req)
+	return true
+}
+
+// forgets a processed request, unless it was replaced while processing
+func complete(item any, req DispatchRequest) {
+	queue.Forget(item)
+
+	queueLock.Lock()
+	defer queueLock.Unlock()
+
+	if current, ok := pending[req.Key]; ok && current.seq == req.seq {
+		delete(pending, req.Key)
+	}
+}
@@ -3,6 +3,9 @@ package controller
 import (
 	"context"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/axatol/actions-job-dispatcher/pkg/cache"
 	"github.com/axatol/actions-job-dispatcher/pkg/config"
@@ -13,14 +16,19 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 )
 
+// guards against overlapping reconciliation passes
+var reconcileLock sync.Mutex
+
 func Reconcile(ctx context.Context) error {
-	jobs, err := k8s.ListJobs(ctx)
-	if err != nil {
-		return fmt.Errorf("failed to list jobs: %s", err)
+	if !reconcileLock.TryLock() {
+		log.Warn().Msg("reconciliation already in progress, skipping")
+		return nil
 	}
 
+	defer reconcileLock.Unlock()
+
 	for _, runner := range config.Runners {
-		if err := reconcileRunner(ctx, runner, jobs); err != nil {
+		if err := reconcileRunner(ctx, runner); err != nil {
 			log.Error().
 				Err(err).
 				Str("runner_scope", runner.Scope.String()).
@@ -33,28 +41,35 @@ func Reconcile(ctx context.Context) error {
 	return nil
 }
 
-func reconcileRunner(ctx context.Context, runner config.RunnerConfig, jobs []batchv1.Job) error {
+func reconcileRunner(ctx context.Context, runner config.RunnerConfig) error {
+	runner = runner.At(time.Now())
+
 	// existing runners
-	var existingRunners []batchv1.Job
-	for _, job := range jobs {
-		labels := k8s.PrefixMapFromLabels(job.Labels).Extract()
-		meta := cache.MetaFromStringMap(labels)
+	existingRunners, err := ListActiveJobs(runner)
+	if err != nil {
+		return err
+	}
 
-		if util.NewSet(runner.Labels...).EqualsStrs(meta.RunnerLabels) {
-			existingRunners = append(existingRunners, job)
+	// runners registered with different labels are not interchangeable
+	groups := map[string]*labelGroup{}
+	group := func(runner config.RunnerConfig) *labelGroup {
+		key := runner.RegisteredLabels().String()
+		if _, ok := groups[key]; !ok {
+			groups[key] = &labelGroup{runner: runner}
 		}
+
+		return groups[key]
 	}
 
+	// the warm pool registers with static labels only
+	group(runner).minIdle = runner.MinIdle
+
 	// queued/in-progress workflow jobs
-	var requestedJobs []cache.WorkflowJobMeta
+	requestedJobs := 0
 	for _, meta := range cache.List() {
-		// ignore if scope doesn't match
-		if meta.Scope.String() != runner.Scope.String() {
-			continue
-		}
-
-		// ignore if labels don't match
-		if !util.NewSet(meta.RunnerLabels...).EqualsStrs(runner.Labels) {
+		// ignore if served by a different runner
+		matched, err := MatchRunner(meta)
+		if err != nil || matched.ID() != runner.ID() {
 			continue
 		}
 
@@ -71,13 +86,14 @@ func reconcileRunner(ctx context.Context, runner config.RunnerConfig, jobs []bat
 		// describe actual job
 		job, err := gh.DescribeWorkflowJob(ctx, &meta)
 		if err != nil {
-			return fmt.Errorf("failed to describe cached job %s: %s", *job.HTMLURL, err)
+			return fmt.Errorf("failed to describe cached job %s: %s", meta.WorkflowJobURL, err)
 		}
 
 		switch job.GetStatus() {
 		case "queued":
 			// a job we should care about
-			requestedJobs = append(requestedJobs, meta)
+			group(*matched).requested = append(group(*matched).requested, meta)
+			requestedJobs += 1
 
 		case "in_progress":
 			// ignore
@@ -88,33 +104,166 @@ func reconcileRunner(ctx context.Context, runner config.RunnerConfig, jobs []bat
 		}
 	}
 
+	// dispatching below must not race the dispatch workers
+	defer lockRunner(runner)()
+
+	existing, err := countActive(runner)
+	if err != nil {
+		return err
+	}
+
+	// runners that have picked up a workflow job are no longer idle
+	busyRunners := util.NewSet()
+	if runner.MinIdle > 0 && len(existingRunners) > 0 {
+		if busyRunners, err = listBusyRunners(ctx, runner); err != nil {
+			return err
+		}
+	}
+
+	busy := 0
+	linked := util.NewSet()
+	for _, job := range existingRunners {
+		linked.Add(fmt.Sprint(k8s.JobWorkflowJobID(job)))
+
+		if meta := JobWorkflowJob(job); meta != nil {
+			log.Debug().
+				Str("job_name", job.Name).
+				Int64("workflow_job_id", meta.WorkflowJobID).
+				Str("workflow_job_url", meta.WorkflowJobURL).
+				Msg("existing runner dispatched for workflow job")
+		}
+
+		labels := k8s.JobRunnerLabels(job)
+		if _, ok := groups[labels]; !ok {
+			continue
+		}
+
+		groups[labels].existing += 1
+		if busyRunners.Has(k8s.RunnerName(job)) {
+			groups[labels].busy += 1
+			busy += 1
+		}
+	}
+
 	log := log.With().
 		Str("runner_scope", runner.Scope.String()).
 		Strs("runner_labels", runner.Labels).
-		Int("existing_runner_count", len(existingRunners)).
-		Int("requested_job_count", len(requestedJobs)).Logger()
+		Int("existing_runner_count", existing).
+		Int("busy_runner_count", busy).
+		Int("requested_job_count", requestedJobs).
+		Int("min_idle", runner.MinIdle).
+		Int("max_replicas", runner.MaxReplicas).Logger()
 
 	// cannot exceed limits
-	if len(existingRunners) >= runner.MaxReplicas {
+	budget := runner.MaxReplicas - existing
+	if budget <= 0 {
 		log.Warn().Msg("runner is at maximum replicas")
 		return nil
 	}
 
-	// enough runners to satisfy jobs
-	if len(existingRunners) >= len(requestedJobs) {
-		log.Debug().Msg("runner replicas sufficient")
-		return nil
+	keys := []string{}
+	for key := range groups {
+		keys = append(keys, key)
 	}
 
-	delta := len(requestedJobs) - len(existingRunners)
-	count := util.ClampInt(delta, 0, runner.MaxReplicas)
-	log.Info().Int("new_jobs", count).Msg("dispatching jobs")
+	sort.Strings(keys)
 
-	for i := 0; i < count; i++ {
-		if err := Dispatch(ctx, runner); err != nil {
-			return err
+	dispatched := 0
+	for _, key := range keys {
+		group := groups[key]
+
+		// enough runners to satisfy jobs and the warm pool
+		delta := len(group.requested) + group.minIdle - (group.existing - group.busy)
+		count := util.ClampInt(delta, 0, budget)
+		if count < 1 {
+			continue
+		}
+
+		budget -= count
+		log.Info().
+			Str("registered_labels", key).
+			Int("new_jobs", count).
+			Msg("dispatching jobs")
+
+		// link new runners to workflow jobs without one, oldest first
+		var unlinked []cache.WorkflowJobMeta
+		for _, meta := range group.requested {
+			if !linked.Has(fmt.Sprint(meta.WorkflowJobID)) {
+				unlinked = append(unlinked, meta)
+			}
+		}
+
+		sort.Slice(unlinked, func(i, j int) bool {
+			return unlinked[i].CreatedAt.Before(unlinked[j].CreatedAt)
+		})
+
+		for i := 0; i < count; i++ {
+			var meta *cache.WorkflowJobMeta
+			if i < len(unlinked) {
+				meta = &unlinked[i]
+			}
+
+			if err := Dispatch(ctx, group.runner, meta); err != nil {
+				return err
+			}
+
+			// runners with the same labels are interchangeable, so this serves
+			// the oldest waiting request
+			discardWaiting(runner.ID(), key, 1)
+			dispatched += 1
 		}
 	}
 
+	if dispatched < 1 {
+		log.Debug().Msg("runner replicas sufficient")
+	}
+
 	return nil
 }
+
+type labelGroup struct {
+	runner    config.RunnerConfig
+	existing  int
+	busy      int
+	requested []cache.WorkflowJobMeta
+	minIdle   int
+}
+
+// unfinished jobs dispatched for the runner config
+func ListActiveJobs(runner config.RunnerConfig) ([]*batchv1.Job, error) {
+	jobs, err := k8s.ListJobsByRunner(runner.ID())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs: %s", err)
+	}
+
+	var active []*batchv1.Job
+	for _, job := range jobs {
+		if !k8s.IsJobFinished(job) {
+			active = append(active, job)
+		}
+	}
+
+	return active, nil
+}
+
+// names of runners currently running a workflow job
+func listBusyRunners(ctx context.Context, runner config.RunnerConfig) (util.Set, error) {
+	client, err := gh.GetClient(ctx, runner.Scope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get github client for %s: %s", runner.Scope.String(), err)
+	}
+
+	runners, err := client.ListRunners(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	busy := util.NewSet()
+	for _, runner := range runners {
+		if runner.GetBusy() {
+			busy.Add(runner.GetName())
+		}
+	}
+
+	return busy, nil
+}
@@ -0,0 +1,109 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/axatol/actions-job-dispatcher/pkg/config"
+	"github.com/axatol/actions-job-dispatcher/pkg/gh"
+	"github.com/axatol/actions-job-dispatcher/pkg/k8s"
+	"github.com/axatol/actions-job-dispatcher/pkg/util"
+	"github.com/rs/zerolog/log"
+	batchv1 "k8s.io/api/batch/v1"
+)
+
+var (
+	watchdogLock sync.Mutex
+	// runners seen registered at least once, an ephemeral runner deregisters
+	// itself once it has finished its job
+	registered = util.NewSet()
+)
+
+// deletes jobs whose runner has not registered with github within the
+// registration timeout, replacements are dispatched by the next reconcile
+func ReapUnregistered(ctx context.Context) ([]string, error) {
+	watchdogLock.Lock()
+	defer watchdogLock.Unlock()
+
+	reaped := []string{}
+	current := util.NewSet()
+
+	for _, runner := range config.Runners {
+		jobs, err := k8s.ListJobsByRunner(runner.ID())
+		if err != nil {
+			return reaped, fmt.Errorf("failed to list jobs: %s", err)
+		}
+
+		var candidates []*batchv1.Job
+		for _, job := range jobs {
+			name := k8s.RunnerName(job)
+			current.Add(name)
+
+			if k8s.IsJobFinished(job) || registered.Has(name) {
+				continue
+			}
+
+			candidates = append(candidates, job)
+		}
+
+		if len(candidates) < 1 {
+			continue
+		}
+
+		client, err := gh.GetClient(ctx, runner.Scope)
+		if err != nil {
+			return reaped, fmt.Errorf("failed to get github client for %s: %s", runner.Scope.String(), err)
+		}
+
+		runners, err := client.ListRunners(ctx)
+		if err != nil {
+			return reaped, err
+		}
+
+		online := util.NewSet()
+		for _, runner := range runners {
+			online.Add(runner.GetName())
+		}
+
+		for _, job := range candidates {
+			name := k8s.RunnerName(job)
+			if online.Has(name) {
+				registered.Add(name)
+				continue
+			}
+
+			age := time.Since(job.CreationTimestamp.Time)
+			if age < config.RegistrationTimeout {
+				continue
+			}
+
+			if err := k8s.DeleteJob(ctx, job.Name); err != nil {
+				log.Error().Err(err).Str("job_name", job.Name).Msg("failed to delete unregistered job")
+				continue
+			}
+
+			log.Warn().
+				Str("job_name", job.Name).
+				Str("runner_name", name).
+				Dur("age", age).
+				Msg("deleted job that never registered a runner")
+
+			reaped = append(reaped, job.Name)
+		}
+	}
+
+	// forget runners whose jobs are gone
+	for name := range registered {
+		if !current.Has(name) {
+			registered.Del(name)
+		}
+	}
+
+	if len(reaped) > 0 {
+		Trigger()
+	}
+
+	return reaped, nil
+}
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/axatol/actions-job-dispatcher/pkg/cache"
+	"github.com/axatol/actions-job-dispatcher/pkg/config"
+	"github.com/google/go-github/v51/github"
+	"github.com/rs/zerolog/log"
+)
+
+var ErrDuplicateTransition = errors.New("duplicate workflow job transition")
+
+// checks a workflow job event is served by one of the runners and has not
+// been seen before
+func AcceptWorkflowJobEvent(e *github.WorkflowJobEvent) (*config.RunnerConfig, error) {
+	runner, err := SelectRunner(e)
+	if err != nil {
+		return nil, err
+	}
+
+	// repo and org hooks may both deliver the same transition
+	if !cache.MarkTransition(e.GetWorkflowJob().GetID(), e.GetAction()) {
+		return nil, ErrDuplicateTransition
+	}
+
+	return runner, nil
+}
+
+// records and acts on an accepted workflow job event, only called on the
+// leader so a shared cache has a single writer, true if a dispatch was queued
+func HandleWorkflowJobEvent(e *github.WorkflowJobEvent, runner config.RunnerConfig) bool {
+	// a recovered or late delivery must not revive a job that already completed
+	if e.GetAction() != "completed" && cache.GetHistory(e.GetWorkflowJob().GetID()) != nil {
+		log.Debug().
+			Int64("workflow_job_id", e.GetWorkflowJob().GetID()).
+			Str("action", e.GetAction()).
+			Msg("workflow job already completed, ignoring event")
+		return false
+	}
+
+	cache.CacheWorkflowJobEvent(e)
+
+	// tear down runners nobody is waiting for anymore
+	if e.GetAction() == "completed" && e.GetWorkflowJob().GetConclusion() == "cancelled" {
+		go func(id int64) {
+			ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+			defer cancel()
+
+			if err := CancelDispatch(ctx, id); err != nil {
+				log.Error().Err(err).Int64("workflow_job_id", id).Msg("failed to cancel dispatch")
+			}
+		}(e.GetWorkflowJob().GetID())
+	}
+
+	// warm pools are topped up by the reconciler, an idle runner may
+	// already be picking this job up
+	if runner.At(time.Now()).MinIdle > 0 {
+		switch e.GetAction() {
+		case "queued", "in_progress":
+			Trigger()
+		}
+
+		return false
+	}
+
+	// capacity is checked by the dispatch workers
+	if e.GetAction() == "queued" {
+		// replays after a restart or failover may already have a runner
+		if ok, err := hasRunnerJob(e.GetWorkflowJob().GetID()); err != nil {
+			log.Warn().Err(err).Int64("workflow_job_id", e.GetWorkflowJob().GetID()).Msg("failed to check for existing runner job")
+		} else if ok {
+			log.Debug().Int64("workflow_job_id", e.GetWorkflowJob().GetID()).Msg("runner job already exists, not dispatching")
+			return false
+		}
+
+		Enqueue(DispatchRequest{
+			Key:           fmt.Sprint(e.GetWorkflowJob().GetID()),
+			WorkflowJobID: e.GetWorkflowJob().GetID(),
+			Runner:        runner,
+		})
+
+		return true
+	}
+
+	return false
+}
+
+// processes a failed delivery fetched from github the same way as a received
+// webhook
+func ReplayDelivery(ctx context.Context, delivery *github.HookDelivery) error {
+	if !cache.MarkDelivery(delivery.GetGUID()) {
+		return nil
+	}
+
+	event, err := delivery.ParseRequestPayload()
+	if err != nil {
+		cache.UnmarkDelivery(delivery.GetGUID())
+		return fmt.Errorf("failed to parse delivery %s: %s", delivery.GetGUID(), err)
+	}
+
+	e, ok := event.(*github.WorkflowJobEvent)
+	if !ok {
+		return nil
+	}
+
+	runner, err := AcceptWorkflowJobEvent(e)
+	if err != nil {
+		log.Debug().Err(err).Str("github_delivery_id", delivery.GetGUID()).Msg("ignoring replayed delivery")
+		return nil
+	}
+
+	HandleWorkflowJobEvent(e, *runner)
+	return nil
+}
@@ -3,6 +3,7 @@ package gh
 import (
 	"context"
 	"net/http"
+	"sync"
 
 	"github.com/axatol/actions-job-dispatcher/pkg/config"
 	"github.com/google/go-github/v51/github"
@@ -10,7 +11,10 @@ import (
 )
 
 // caches an instance of the client for each authenticated scope
-var clients = map[string]Client{}
+var (
+	clientsLock sync.Mutex
+	clients     = map[string]Client{}
+)
 
 type Client struct {
 	client *github.Client
@@ -18,6 +22,9 @@ type Client struct {
 }
 
 func GetClient(ctx context.Context, scope config.Scope) (*Client, error) {
+	clientsLock.Lock()
+	defer clientsLock.Unlock()
+
 	if client, ok := clients[scope.String()]; ok {
 		return &client, nil
 	}
@@ -30,7 +37,7 @@ func GetClient(ctx context.Context, scope config.Scope) (*Client, error) {
 	cache := httpcache.NewTransport(httpcache.NewMemoryCache())
 	cache.Transport = authTransport
 
-	logging := loggingTransport{Transport: cache}
+	logging := loggingTransport{Transport: cache, scope: scope}
 
 	httpClient := http.Client{Transport: logging}
 	githubClient := github.NewClient(&httpClient)
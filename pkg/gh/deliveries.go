@@ -0,0 +1,193 @@
+package gh
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/axatol/actions-job-dispatcher/pkg/config"
+	"github.com/axatol/actions-job-dispatcher/pkg/util"
+	"github.com/google/go-github/v51/github"
+	"github.com/rs/zerolog/log"
+)
+
+// processes a fetched delivery as if it had been received by the webhook
+type ReplayFunc func(ctx context.Context, delivery *github.HookDelivery) error
+
+var (
+	recoveryOnce sync.Once
+	// guids already recovered, so a pending redelivery is not requested twice
+	recovered *util.TTLSet
+)
+
+// recent deliveries of a hook, newest first, back to since
+func (c *Client) ListHookDeliveries(ctx context.Context, hookID int64, since time.Time) ([]*github.HookDelivery, error) {
+	var (
+		opts          = &github.ListCursorOptions{PerPage: 100}
+		allDeliveries []*github.HookDelivery
+		deliveries    []*github.HookDelivery
+		resp          *github.Response
+		err           error
+	)
+
+	for {
+		if c.scope.IsOrg {
+			deliveries, resp, err = c.client.Organizations.ListHookDeliveries(ctx, c.scope.String(), hookID, opts)
+		} else {
+			deliveries, resp, err = c.client.Repositories.ListHookDeliveries(ctx, c.scope.Owner, c.scope.Repository, hookID, opts)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to list deliveries of hook %d for %s: %s", hookID, c.scope.String(), err)
+		}
+
+		for _, delivery := range deliveries {
+			if delivery.GetDeliveredAt().Before(since) {
+				return allDeliveries, nil
+			}
+
+			allDeliveries = append(allDeliveries, delivery)
+		}
+
+		if resp.Cursor == "" {
+			break
+		}
+
+		opts.Cursor = resp.Cursor
+	}
+
+	return allDeliveries, nil
+}
+
+// includes the request payload
+func (c *Client) GetHookDelivery(ctx context.Context, hookID, deliveryID int64) (*github.HookDelivery, error) {
+	var (
+		delivery *github.HookDelivery
+		err      error
+	)
+
+	if c.scope.IsOrg {
+		delivery, _, err = c.client.Organizations.GetHookDelivery(ctx, c.scope.String(), hookID, deliveryID)
+	} else {
+		delivery, _, err = c.client.Repositories.GetHookDelivery(ctx, c.scope.Owner, c.scope.Repository, hookID, deliveryID)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to get delivery %d of hook %d for %s: %s", deliveryID, hookID, c.scope.String(), err)
+	}
+
+	return delivery, nil
+}
+
+func (c *Client) RedeliverHookDelivery(ctx context.Context, hookID, deliveryID int64) error {
+	var err error
+	if c.scope.IsOrg {
+		_, _, err = c.client.Organizations.RedeliverHookDelivery(ctx, c.scope.String(), hookID, deliveryID)
+	} else {
+		_, _, err = c.client.Repositories.RedeliverHookDelivery(ctx, c.scope.Owner, c.scope.Repository, hookID, deliveryID)
+	}
+
+	// redelivery is accepted asynchronously
+	if _, ok := err.(*github.AcceptedError); ok {
+		err = nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to redeliver delivery %d of hook %d for %s: %s", deliveryID, hookID, c.scope.String(), err)
+	}
+
+	return nil
+}
+
+// workflow_job deliveries without a successful attempt, one per guid
+func (c *Client) ListFailedDeliveries(ctx context.Context, hookID int64, since time.Time) ([]*github.HookDelivery, error) {
+	deliveries, err := c.ListHookDeliveries(ctx, hookID, since)
+	if err != nil {
+		return nil, err
+	}
+
+	succeeded := util.NewSet()
+	for _, delivery := range deliveries {
+		if code := delivery.GetStatusCode(); code >= 200 && code < 300 {
+			succeeded.Add(delivery.GetGUID())
+		}
+	}
+
+	failed := []*github.HookDelivery{}
+	seen := util.NewSet()
+	for _, delivery := range deliveries {
+		if delivery.GetEvent() != "workflow_job" {
+			continue
+		}
+
+		if succeeded.Has(delivery.GetGUID()) || seen.Has(delivery.GetGUID()) {
+			continue
+		}
+
+		seen.Add(delivery.GetGUID())
+		failed = append(failed, delivery)
+	}
+
+	return failed, nil
+}
+
+// checks each configured hook for failed workflow_job deliveries and either
+// requests a redelivery or hands the payload to replay
+func RecoverDeliveries(ctx context.Context, replay ReplayFunc) error {
+	recoveryOnce.Do(func() {
+		recovered = util.NewTTLSet(config.DeliveryRecoveryWindow, config.DedupeSize)
+	})
+
+	since := time.Now().Add(-config.DeliveryRecoveryWindow)
+	for _, hook := range config.Hooks {
+		client, err := GetClient(ctx, hook.Scope)
+		if err != nil {
+			return fmt.Errorf("failed to get github client for %s: %s", hook.Scope.String(), err)
+		}
+
+		failed, err := client.ListFailedDeliveries(ctx, hook.ID, since)
+		if err != nil {
+			return err
+		}
+
+		for _, delivery := range failed {
+			if !recovered.Add(delivery.GetGUID()) {
+				continue
+			}
+
+			log := log.With().
+				Str("hook", hook.String()).
+				Int64("delivery_id", delivery.GetID()).
+				Str("github_delivery_id", delivery.GetGUID()).
+				Int("status_code", delivery.GetStatusCode()).
+				Str("recovery_mode", config.DeliveryRecoveryMode.String()).
+				Logger()
+
+			if err := client.recoverDelivery(ctx, hook.ID, delivery, replay); err != nil {
+				// try again on the next pass
+				recovered.Del(delivery.GetGUID())
+				log.Error().Err(err).Msg("failed to recover delivery")
+				continue
+			}
+
+			log.Info().Msg("recovered failed delivery")
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) recoverDelivery(ctx context.Context, hookID int64, delivery *github.HookDelivery, replay ReplayFunc) error {
+	if config.DeliveryRecoveryMode == config.RedeliverRecoveryMode {
+		return c.RedeliverHookDelivery(ctx, hookID, delivery.GetID())
+	}
+
+	// listed deliveries do not include the payload
+	full, err := c.GetHookDelivery(ctx, hookID, delivery.GetID())
+	if err != nil {
+		return err
+	}
+
+	return replay(ctx, full)
+}
@@ -5,12 +5,13 @@ import (
 	"fmt"
 
 	"github.com/axatol/actions-job-dispatcher/pkg/cache"
+	"github.com/axatol/actions-job-dispatcher/pkg/config"
 	"github.com/google/go-github/v51/github"
 )
 
 func (c *Client) ListRunners(ctx context.Context) ([]*github.Runner, error) {
 	var (
-		opts       *github.ListOptions
+		opts       = &github.ListOptions{PerPage: 100}
 		allRunners []*github.Runner
 		runners    *github.Runners
 		resp       *github.Response
@@ -39,6 +40,21 @@ func (c *Client) ListRunners(ctx context.Context) ([]*github.Runner, error) {
 	return allRunners, nil
 }
 
+func (c *Client) RemoveRunner(ctx context.Context, id int64) error {
+	var err error
+	if c.scope.IsOrg {
+		_, err = c.client.Actions.RemoveOrganizationRunner(ctx, c.scope.String(), id)
+	} else {
+		_, err = c.client.Actions.RemoveRunner(ctx, c.scope.Owner, c.scope.Repository, id)
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to remove runner %d for %s: %s", id, c.scope.String(), err)
+	}
+
+	return nil
+}
+
 func (c *Client) DescribeScope(ctx context.Context) (string, error) {
 	var (
 		scope interface{ GetHTMLURL() string }
@@ -85,3 +101,134 @@ func (c Client) DescribeWorkflowJob(ctx context.Context, meta *cache.WorkflowJob
 
 	return job, nil
 }
+
+// repositories to search for workflow runs, all repositories visible to the
+// client if scoped to an organisation
+func (c *Client) ListRepositories(ctx context.Context) ([]string, error) {
+	if !c.scope.IsOrg {
+		return []string{c.scope.Repository}, nil
+	}
+
+	opts := &github.RepositoryListByOrgOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	var names []string
+
+	for {
+		repos, resp, err := c.client.Repositories.ListByOrg(ctx, c.scope.Owner, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list repositories for %s: %s", c.scope.String(), err)
+		}
+
+		for _, repo := range repos {
+			if !repo.GetArchived() {
+				names = append(names, repo.GetName())
+			}
+		}
+
+		if resp.NextPage < 1 {
+			break
+		}
+
+		opts.Page = resp.NextPage
+	}
+
+	return names, nil
+}
+
+func (c *Client) ListWorkflowRuns(ctx context.Context, repository, status string) ([]*github.WorkflowRun, error) {
+	opts := &github.ListWorkflowRunsOptions{Status: status, ListOptions: github.ListOptions{PerPage: 100}}
+	var allRuns []*github.WorkflowRun
+
+	for {
+		runs, resp, err := c.client.Actions.ListRepositoryWorkflowRuns(ctx, c.scope.Owner, repository, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s workflow runs for %s/%s: %s", status, c.scope.Owner, repository, err)
+		}
+
+		allRuns = append(allRuns, runs.WorkflowRuns...)
+		if resp.NextPage < 1 {
+			break
+		}
+
+		opts.Page = resp.NextPage
+	}
+
+	return allRuns, nil
+}
+
+func (c *Client) ListWorkflowJobs(ctx context.Context, repository string, runID int64) ([]*github.WorkflowJob, error) {
+	opts := &github.ListWorkflowJobsOptions{Filter: "latest", ListOptions: github.ListOptions{PerPage: 100}}
+	var allJobs []*github.WorkflowJob
+
+	for {
+		jobs, resp, err := c.client.Actions.ListWorkflowJobs(ctx, c.scope.Owner, repository, runID, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list workflow jobs for %s/%s run %d: %s", c.scope.Owner, repository, runID, err)
+		}
+
+		allJobs = append(allJobs, jobs.Jobs...)
+		if resp.NextPage < 1 {
+			break
+		}
+
+		opts.Page = resp.NextPage
+	}
+
+	return allJobs, nil
+}
+
+// a workflow job and the repository scope it belongs to
+type ScopedWorkflowJob struct {
+	Scope config.Scope
+	Job   *github.WorkflowJob
+}
+
+// queued and in progress jobs belonging to queued and in progress workflow runs
+func (c *Client) ListActiveWorkflowJobs(ctx context.Context) ([]ScopedWorkflowJob, error) {
+	repositories, err := c.ListRepositories(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []ScopedWorkflowJob
+	for _, repository := range repositories {
+		scope := c.scope
+		scope.Repository = repository
+
+		for _, status := range []string{"queued", "in_progress"} {
+			runs, err := c.ListWorkflowRuns(ctx, repository, status)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, run := range runs {
+				jobs, err := c.ListWorkflowJobs(ctx, repository, run.GetID())
+				if err != nil {
+					return nil, err
+				}
+
+				for _, job := range jobs {
+					switch job.GetStatus() {
+					case "queued", "in_progress":
+						results = append(results, ScopedWorkflowJob{Scope: scope, Job: job})
+					}
+				}
+			}
+		}
+	}
+
+	return results, nil
+}
+
+func (c *Client) ListPendingWorkflowJobs(ctx context.Context) ([]cache.WorkflowJobMeta, error) {
+	jobs, err := c.ListActiveWorkflowJobs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []cache.WorkflowJobMeta
+	for _, job := range jobs {
+		results = append(results, *cache.WorkflowJobMetaFromJob(job.Scope, job.Job))
+	}
+
+	return results, nil
+}
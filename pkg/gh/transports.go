@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/axatol/actions-job-dispatcher/pkg/config"
 	"github.com/bradleyfalzon/ghinstallation/v2"
@@ -18,6 +21,7 @@ const (
 	headerRateLimitRemaining = "x-ratelimit-remaining"
 	headerRateLimitUsed      = "x-ratelimit-used"
 	headerRateLimitReset     = "x-ratelimit-reset"
+	headerRetryAfter         = "retry-after"
 )
 
 type RateLimit struct {
@@ -25,9 +29,43 @@ type RateLimit struct {
 	Remaining string `json:"remaining"`
 	Used      string `json:"-"`
 	Reset     string `json:"-"`
+	// set by secondary rate limits
+	RetryAfter time.Time `json:"-"`
 }
 
-var usage = map[string]RateLimit{}
+var (
+	usageLock sync.Mutex
+	usage     = map[string]RateLimit{}
+)
+
+// when requests are expected to succeed again, if fewer than reserve remain
+// or a secondary rate limit was hit
+func (c *Client) RateLimitedUntil(reserve int) (time.Time, bool) {
+	usageLock.Lock()
+	limit, ok := usage[c.scope.String()]
+	usageLock.Unlock()
+
+	if !ok {
+		return time.Time{}, false
+	}
+
+	if time.Now().Before(limit.RetryAfter) {
+		return limit.RetryAfter, true
+	}
+
+	remaining, err := strconv.Atoi(limit.Remaining)
+	if err != nil || remaining >= reserve {
+		return time.Time{}, false
+	}
+
+	reset, err := strconv.ParseInt(limit.Reset, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	until := time.Unix(reset, 0)
+	return until, time.Now().Before(until)
+}
 
 type loggingTransport struct {
 	Transport http.RoundTripper
@@ -47,17 +85,28 @@ func (t loggingTransport) log(req *http.Request, res *http.Response, err error)
 	}
 
 	if res != nil {
-		usage[t.scope.String()] = RateLimit{
+		limit := RateLimit{
 			Limit:     res.Header.Get(headerRateLimitLimit),
 			Remaining: res.Header.Get(headerRateLimitRemaining),
 			Used:      res.Header.Get(headerRateLimitUsed),
 			Reset:     res.Header.Get(headerRateLimitReset),
 		}
 
+		if seconds, err := strconv.Atoi(res.Header.Get(headerRetryAfter)); err == nil {
+			limit.RetryAfter = time.Now().Add(time.Duration(seconds) * time.Second)
+		}
+
+		// responses served from the cache carry stale headers
+		if res.Header.Get(httpcache.XFromCache) != "1" {
+			usageLock.Lock()
+			usage[t.scope.String()] = limit
+			usageLock.Unlock()
+		}
+
 		event = event.
 			Int("status_code", res.StatusCode).
 			Bool("cache_hit", res.Header.Get(httpcache.XFromCache) == "1").
-			Str("rate_limit_remaining", usage[t.scope.String()].Remaining)
+			Str("rate_limit_remaining", limit.Remaining)
 	}
 
 	event.
@@ -4,6 +4,7 @@ import (
 	"crypto/sha1"
 	"encoding/hex"
 	"fmt"
+	"math/rand"
 	"strconv"
 	"time"
 
@@ -11,7 +12,6 @@ import (
 	"github.com/axatol/actions-job-dispatcher/pkg/util"
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
-	"k8s.io/apimachinery/pkg/api/resource"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 )
@@ -20,6 +20,13 @@ var (
 	JobSelectorKey   = "app.kubernetes.io/managed-by"
 	JobSelectorValue = "actions-job-dispatcher"
 	JobSelector      = labels.Set(map[string]string{JobSelectorKey: JobSelectorValue})
+
+	RunnerLabelKey      = fmt.Sprintf("%s/runner", prefixKey)
+	ScopeLabelKey       = fmt.Sprintf("%s/scope", prefixKey)
+	WorkflowJobLabelKey = fmt.Sprintf("%s/workflow-job-id", prefixKey)
+	WorkflowRunLabelKey = fmt.Sprintf("%s/workflow-id", prefixKey)
+
+	RunnerContainerName = config.RunnerContainerName
 )
 
 type Job struct {
@@ -31,7 +38,7 @@ type Job struct {
 func (j Job) Hash(labels config.Labels) string {
 	hasher := sha1.New()
 	hasher.Write([]byte(labels.String()))
-	hasher.Write([]byte(fmt.Sprint(time.Now().Unix())))
+	hasher.Write([]byte(fmt.Sprint(time.Now().UnixNano(), rand.Int63())))
 	return hex.EncodeToString(hasher.Sum(nil))
 }
 
@@ -40,7 +47,7 @@ func (j Job) AddLabel(key, value string) {
 		j.Labels = PrefixMap{}
 	}
 
-	j.Labels.Add(key, value)
+	j.Labels.Add(key, labelValue(value))
 }
 
 func (j Job) AddAnnotation(key, value string) {
@@ -60,28 +67,44 @@ func (j Job) AddEnv(key, value string) {
 }
 
 // note: need to include env vars "RUNNER_TOKEN" with a registration token
-func (j Job) Render(runner config.RunnerConfig) batchv1.Job {
-	name := fmt.Sprintf("runner-%s-%s", runner.Slug(), j.Hash(runner.Labels)[:8])
+func (j Job) Render(runner config.RunnerConfig) (batchv1.Job, error) {
+	name := RunnerNamePrefix(runner) + j.Hash(runner.Labels)[:8]
+
+	// matched labels may imply a different architecture
+	nodeSelector, err := runner.PodNodeSelector()
+	if err != nil {
+		return batchv1.Job{}, err
+	}
+
+	resources, err := runner.Resources.Requirements()
+	if err != nil {
+		return batchv1.Job{}, err
+	}
+
+	var runtimeClassName *string
+	if runner.RuntimeClassName != "" {
+		runtimeClassName = &runner.RuntimeClassName
+	}
 
 	// labels
+	j.Labels[JobSelectorKey] = JobSelectorValue
 	j.AddLabel("is-org", strconv.FormatBool(runner.Scope.IsOrg))
 	j.AddLabel("repository-owner", runner.Scope.Owner)
 	j.AddLabel("repository-name", runner.Scope.Repository)
-	j.AddLabel("runner-labels", runner.Labels.String())
-	j.AddLabel("scope", runner.Scope.String())
+	j.AddLabel(RunnerLabelKey, runner.ID())
+	j.AddLabel(ScopeLabelKey, runner.Scope.Slug())
+
+	// annotations, values may not be valid labels
+	j.AddAnnotation("runner", runner.Slug())
+	j.AddAnnotation("runner-labels", runner.RegisteredLabels().String())
+	j.AddAnnotation("scope", runner.Scope.String())
 
 	// environment variables
 	j.AddEnv("DISABLE_RUNNER_UPDATE", "true")
-	j.AddEnv("DOCKERD_IN_RUNNER", "true")
-	// j.AddEnv("DOCKER_CERT_PATH", "/certs/client")
-	j.AddEnv("DOCKER_ENABLED", "true")
-	// j.AddEnv("DOCKER_HOST", "tcp://localhost:2376")
-	// j.AddEnv("DOCKER_TLS_VERIFY", "1")
 	j.AddEnv("GITHUB_ACTIONS_RUNNER_EXTRA_USER_AGENT", "actions-job-dispatcher/v0.0.1")
 	j.AddEnv("GITHUB_URL", "https://github.com/")
-	j.AddEnv("MTU", "1400")
 	j.AddEnv("RUNNER_EPHEMERAL", "true")
-	j.AddEnv("RUNNER_LABELS", runner.Labels.String())
+	j.AddEnv("RUNNER_LABELS", runner.RegisteredLabels().String())
 	j.AddEnv("RUNNER_NAME", name)
 	j.AddEnv("RUNNER_STATUS_UPDATE_HOOK", "false")
 	j.AddEnv("RUNNER_WORKDIR", "/runner/_work")
@@ -92,11 +115,19 @@ func (j Job) Render(runner config.RunnerConfig) batchv1.Job {
 		j.AddEnv("RUNNER_ORG", runner.Scope.Owner)
 	}
 
-	return batchv1.Job{
+	j.addDockerEnv(runner.DockerMode)
+
+	// setup-* actions install into and reuse toolchains from here
+	if cache := runner.Caches.ToolCache(); cache != nil {
+		j.AddEnv("RUNNER_TOOL_CACHE", cache.Path)
+	}
+
+	job := batchv1.Job{
 		ObjectMeta: v1.ObjectMeta{
-			Name:      name,
-			Namespace: config.Namespace,
-			Labels:    j.Labels,
+			Name:        name,
+			Namespace:   config.Namespace,
+			Labels:      j.Labels,
+			Annotations: j.Annotations,
 		},
 
 		Spec: batchv1.JobSpec{
@@ -108,6 +139,10 @@ func (j Job) Render(runner config.RunnerConfig) batchv1.Job {
 			TTLSecondsAfterFinished: util.Ptr(int32(time.Minute.Seconds())),
 
 			Template: corev1.PodTemplateSpec{
+				ObjectMeta: v1.ObjectMeta{
+					Labels: j.Labels,
+				},
+
 				Spec: corev1.PodSpec{
 					TerminationGracePeriodSeconds: util.Ptr(int64((time.Minute * 5).Seconds())),
 					ServiceAccountName:            runner.ServiceAccountName,
@@ -115,20 +150,23 @@ func (j Job) Render(runner config.RunnerConfig) batchv1.Job {
 					DNSPolicy:                     corev1.DNSClusterFirst,
 					EnableServiceLinks:            util.Ptr(true),
 
+					NodeSelector:              nodeSelector,
+					Tolerations:               runner.Tolerations,
+					Affinity:                  (*corev1.Affinity)(runner.Affinity),
+					TopologySpreadConstraints: runner.TopologySpreadConstraints,
+					PriorityClassName:         runner.PriorityClassName,
+					RuntimeClassName:          runtimeClassName,
+
 					Containers: []corev1.Container{{
-						Name:            "runner",
+						Name:            RunnerContainerName,
 						Image:           runner.Image,
 						ImagePullPolicy: corev1.PullAlways,
 
-						Resources: corev1.ResourceRequirements{
-							Limits: corev1.ResourceList{
-								corev1.ResourceCPU:    resource.MustParse(runner.Resources.CPULimit),
-								corev1.ResourceMemory: resource.MustParse(runner.Resources.MemoryLimit),
-							},
-						},
+						Resources: resources,
 
+						// only dockerd in the runner needs privileges
 						SecurityContext: &corev1.SecurityContext{
-							Privileged: util.Ptr(true),
+							Privileged: util.Ptr(runner.DockerMode == "" || runner.DockerMode == config.DockerInRunner),
 						},
 
 						// LivenessProbe: ,
@@ -160,6 +198,72 @@ func (j Job) Render(runner config.RunnerConfig) batchv1.Job {
 			},
 		},
 	}
+
+	addCaches(&job.Spec.Template.Spec, runner)
+
+	if runner.DockerMode == config.DockerSidecar {
+		if err := addDockerSidecar(&job.Spec.Template.Spec, runner); err != nil {
+			return job, err
+		}
+	}
+
+	template, err := runner.PodTemplate.Apply(job.Spec.Template)
+	if err != nil {
+		return job, err
+	}
+
+	// the dispatcher finds its pods by these
+	if template.Labels == nil {
+		template.Labels = map[string]string{}
+	}
+
+	for key, value := range j.Labels {
+		template.Labels[key] = value
+	}
+
+	job.Spec.Template = template
+	return job, nil
+}
+
+// all runners registered for a runner config share this prefix, leaving room
+// for the hash suffix within the job name length limit
+func RunnerNamePrefix(runner config.RunnerConfig) string {
+	return fmt.Sprintf("runner-%s-", dnsLabel(runner.Slug(), 47))
+}
+
+// the name the runner registers with github
+func RunnerName(job *batchv1.Job) string {
+	for _, container := range job.Spec.Template.Spec.Containers {
+		for _, env := range container.Env {
+			if env.Name == "RUNNER_NAME" {
+				return env.Value
+			}
+		}
+	}
+
+	return job.Name
+}
+
+// comma separated labels the job's runner registers with
+func JobRunnerLabels(job *batchv1.Job) string {
+	return PrefixMapFromLabels(job.Annotations).Extract()["runner-labels"]
+}
+
+// id of the workflow job the job was dispatched for, 0 if unknown
+func JobWorkflowJobID(job *batchv1.Job) int64 {
+	id, _ := strconv.ParseInt(job.Labels[WorkflowJobLabelKey], 10, 64)
+	return id
+}
+
+// the workflow job a runner pod was dispatched for, 0 for warm pool runners
+func PodWorkflowJobID(pod *corev1.Pod) int64 {
+	id, _ := strconv.ParseInt(pod.Labels[WorkflowJobLabelKey], 10, 64)
+	return id
+}
+
+// whether the job was dispatched for the runner config
+func IsRunnerJob(job *batchv1.Job, runner config.RunnerConfig) bool {
+	return job.Labels[RunnerLabelKey] == runner.ID()
 }
 
 func NewRunnerJob() Job {
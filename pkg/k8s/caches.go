@@ -0,0 +1,109 @@
+package k8s
+
+import (
+	"path"
+
+	"github.com/axatol/actions-job-dispatcher/pkg/config"
+	"github.com/axatol/actions-job-dispatcher/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func cacheVolumeName(cache config.CacheVolume) string {
+	return "cache-" + cache.Name
+}
+
+func cacheVolume(cache config.CacheVolume) corev1.Volume {
+	volume := corev1.Volume{Name: cacheVolumeName(cache)}
+	switch {
+	case cache.ClaimName != "":
+		volume.PersistentVolumeClaim = &corev1.PersistentVolumeClaimVolumeSource{ClaimName: cache.ClaimName}
+
+	case cache.Ephemeral != nil:
+		spec := corev1.PersistentVolumeClaimSpec(*cache.Ephemeral)
+		if len(spec.AccessModes) < 1 {
+			spec.AccessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+		}
+
+		volume.Ephemeral = &corev1.EphemeralVolumeSource{
+			VolumeClaimTemplate: &corev1.PersistentVolumeClaimTemplate{Spec: spec},
+		}
+
+	case cache.HostPath != "":
+		volume.HostPath = &corev1.HostPathVolumeSource{
+			Path: cache.HostPath,
+			Type: util.Ptr(corev1.HostPathDirectoryOrCreate),
+		}
+	}
+
+	return volume
+}
+
+func cacheVolumeMount(cache config.CacheVolume) corev1.VolumeMount {
+	mount := corev1.VolumeMount{
+		Name:      cacheVolumeName(cache),
+		MountPath: cache.Path,
+	}
+
+	if cache.PerPod() {
+		mount.SubPathExpr = "$(POD_NAME)"
+	}
+
+	return mount
+}
+
+// mounts a cache into a container, exposing the pod name for per pod subpaths
+func mountCache(container *corev1.Container, cache config.CacheVolume) {
+	container.VolumeMounts = append(container.VolumeMounts, cacheVolumeMount(cache))
+	if !cache.PerPod() {
+		return
+	}
+
+	for _, env := range container.Env {
+		if env.Name == "POD_NAME" {
+			return
+		}
+	}
+
+	container.Env = append(container.Env, corev1.EnvVar{
+		Name: "POD_NAME",
+		ValueFrom: &corev1.EnvVarSource{
+			FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"},
+		},
+	})
+}
+
+// mounts cache volumes into the runner, except the docker data root which
+// belongs to the sidecar when dockerd runs there
+func addCaches(spec *corev1.PodSpec, runner config.RunnerConfig) {
+	if len(runner.Caches) < 1 {
+		return
+	}
+
+	// volumes other than host paths are made writable for the runner, only
+	// changing ownership when the volume root does not match
+	fsGroup := config.DefaultFSGroup
+	if runner.FSGroup != nil {
+		fsGroup = *runner.FSGroup
+	}
+
+	if spec.SecurityContext == nil {
+		spec.SecurityContext = &corev1.PodSecurityContext{}
+	}
+
+	spec.SecurityContext.FSGroup = util.Ptr(fsGroup)
+	spec.SecurityContext.FSGroupChangePolicy = util.Ptr(corev1.FSGroupChangeOnRootMismatch)
+
+	for _, cache := range runner.Caches {
+		spec.Volumes = append(spec.Volumes, cacheVolume(cache))
+
+		if runner.DockerMode == config.DockerSidecar && path.Clean(cache.Path) == config.DockerDataPath {
+			continue
+		}
+
+		for i, container := range spec.Containers {
+			if container.Name == RunnerContainerName {
+				mountCache(&spec.Containers[i], cache)
+			}
+		}
+	}
+}
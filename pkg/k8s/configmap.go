@@ -0,0 +1,113 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/retry"
+)
+
+// applies mutate to the data of a configmap, creating it if it does not exist,
+// and retries if another replica updated it in the meantime
+func (c *Client) UpdateConfigMap(ctx context.Context, name string, mutate func(data map[string]string)) (*corev1.ConfigMap, error) {
+	var result *corev1.ConfigMap
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		configMaps := c.client.CoreV1().ConfigMaps(c.namespace)
+
+		configMap, err := configMaps.Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			configMap = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: c.namespace},
+				Data:       map[string]string{},
+			}
+
+			mutate(configMap.Data)
+			result, err = configMaps.Create(ctx, configMap, metav1.CreateOptions{})
+			if errors.IsAlreadyExists(err) {
+				// lost the race to create it, try again as an update
+				return errors.NewConflict(corev1.Resource("configmaps"), name, err)
+			}
+
+			return err
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if configMap.Data == nil {
+			configMap.Data = map[string]string{}
+		}
+
+		mutate(configMap.Data)
+		result, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+		return err
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to update configmap %s/%s: %s", c.namespace, name, err)
+	}
+
+	return result, nil
+}
+
+func UpdateConfigMap(ctx context.Context, name string, mutate func(data map[string]string)) (*corev1.ConfigMap, error) {
+	client, err := GetClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get kubernetes client: %s", err)
+	}
+
+	return client.UpdateConfigMap(ctx, name, mutate)
+}
+
+// calls handler with the data of a configmap whenever it changes, an empty map
+// if it is deleted, and blocks until the initial state has been synced
+func (c *Client) WatchConfigMap(ctx context.Context, name string, handler func(data map[string]string)) error {
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		c.client,
+		0,
+		informers.WithNamespace(c.namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.FieldSelector = fields.OneTermEqualSelector("metadata.name", name).String()
+		}),
+	)
+
+	data := func(obj any) map[string]string {
+		if configMap, ok := obj.(*corev1.ConfigMap); ok && configMap.Data != nil {
+			return configMap.Data
+		}
+
+		return map[string]string{}
+	}
+
+	informer := factory.Core().V1().ConfigMaps().Informer()
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj any) { handler(data(obj)) },
+		UpdateFunc: func(_, obj any) { handler(data(obj)) },
+		DeleteFunc: func(any) { handler(map[string]string{}) },
+	}); err != nil {
+		return fmt.Errorf("failed to watch configmap %s/%s: %s", c.namespace, name, err)
+	}
+
+	factory.Start(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		return fmt.Errorf("failed to sync configmap %s/%s", c.namespace, name)
+	}
+
+	return nil
+}
+
+func WatchConfigMap(ctx context.Context, name string, handler func(data map[string]string)) error {
+	client, err := GetClient()
+	if err != nil {
+		return fmt.Errorf("failed to get kubernetes client: %s", err)
+	}
+
+	return client.WatchConfigMap(ctx, name, handler)
+}
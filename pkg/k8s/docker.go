@@ -0,0 +1,121 @@
+package k8s
+
+import (
+	"github.com/axatol/actions-job-dispatcher/pkg/config"
+	"github.com/axatol/actions-job-dispatcher/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	DockerContainerName = "docker"
+
+	dockerCertsVolume = "docker-certs"
+	dockerDataVolume  = "docker-data"
+	dockerMTU         = "1400"
+
+	// seconds to wait for the runner to start before giving up
+	dockerStartupTimeout = "600"
+)
+
+// stops dockerd once the runner has started and exited, otherwise the sidecar
+// would keep the job running forever. matches the process name rather than
+// the command line, which would match this script
+const dockerSidecarScript = `dockerd-entrypoint.sh --mtu=` + dockerMTU + ` &
+waited=0
+until pgrep -x Runner.Listener > /dev/null; do
+  if [ "$waited" -ge ` + dockerStartupTimeout + ` ]; then
+    echo "runner did not start within ` + dockerStartupTimeout + `s, stopping dockerd"
+    break
+  fi
+  sleep 1
+  waited=$((waited + 1))
+done
+while pgrep -x Runner.Listener > /dev/null; do sleep 1; done
+kill $!
+wait`
+
+// environment variables the runner uses to find or start docker
+func (j Job) addDockerEnv(mode config.DockerMode) {
+	switch mode {
+	case config.DockerSidecar:
+		j.AddEnv("DOCKER_ENABLED", "true")
+		j.AddEnv("DOCKERD_IN_RUNNER", "false")
+		j.AddEnv("DOCKER_HOST", "tcp://localhost:2376")
+		j.AddEnv("DOCKER_TLS_VERIFY", "1")
+		j.AddEnv("DOCKER_CERT_PATH", "/certs/client")
+
+	case config.DockerNone:
+		j.AddEnv("DOCKER_ENABLED", "false")
+		j.AddEnv("DOCKERD_IN_RUNNER", "false")
+
+	default:
+		j.AddEnv("DOCKER_ENABLED", "true")
+		j.AddEnv("DOCKERD_IN_RUNNER", "true")
+		j.AddEnv("MTU", dockerMTU)
+	}
+}
+
+// adds a privileged docker:dind container sharing tls certs and the work
+// directory with an unprivileged runner
+func addDockerSidecar(spec *corev1.PodSpec, runner config.RunnerConfig) error {
+	resources, err := runner.DockerResources.Requirements()
+	if err != nil {
+		return err
+	}
+
+	image := runner.DockerImage
+	if image == "" {
+		image = config.DefaultDockerImage
+	}
+
+	// lets the sidecar see the runner process
+	spec.ShareProcessNamespace = util.Ptr(true)
+
+	for i, container := range spec.Containers {
+		if container.Name == RunnerContainerName {
+			spec.Containers[i].VolumeMounts = append(spec.Containers[i].VolumeMounts, corev1.VolumeMount{
+				Name:      dockerCertsVolume,
+				MountPath: "/certs/client",
+				SubPath:   "client",
+				ReadOnly:  true,
+			})
+		}
+	}
+
+	sidecar := corev1.Container{
+		Name:    DockerContainerName,
+		Image:   image,
+		Command: []string{"/bin/sh", "-c", dockerSidecarScript},
+
+		Resources: resources,
+
+		SecurityContext: &corev1.SecurityContext{
+			Privileged: util.Ptr(true),
+		},
+
+		Env: []corev1.EnvVar{
+			// the entrypoint generates ca, server and client certs here
+			{Name: "DOCKER_TLS_CERTDIR", Value: "/certs"},
+		},
+
+		VolumeMounts: []corev1.VolumeMount{
+			{MountPath: "/certs", Name: dockerCertsVolume},
+			// bind mounts of the workspace must resolve in the daemon
+			{MountPath: "/runner/_work", Name: "work"},
+		},
+	}
+
+	spec.Volumes = append(spec.Volumes, corev1.Volume{Name: dockerCertsVolume, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}})
+
+	// a cache replaces the throwaway data root
+	if cache := runner.Caches.At(config.DockerDataPath); cache != nil {
+		mountCache(&sidecar, *cache)
+	} else {
+		sidecar.VolumeMounts = append(sidecar.VolumeMounts, corev1.VolumeMount{MountPath: config.DockerDataPath, Name: dockerDataVolume})
+		spec.Volumes = append(spec.Volumes, corev1.Volume{Name: dockerDataVolume, VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}})
+	}
+
+	spec.Containers = append(spec.Containers, sidecar)
+
+	return nil
+}
@@ -3,13 +3,55 @@ package k8s
 import (
 	"fmt"
 	"reflect"
+	"regexp"
 	"strings"
+	"time"
 
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 const prefixKey = "actions-job-dispatcher"
 
+var invalidLabelChars = regexp.MustCompile(`[^A-Za-z0-9_.-]`)
+
+// coerces a string into a valid label value
+func labelValue(s string) string {
+	s = invalidLabelChars.ReplaceAllString(s, "_")
+	if len(s) > validation.LabelValueMaxLength {
+		s = s[:validation.LabelValueMaxLength]
+	}
+
+	return strings.Trim(s, "_.-")
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// coerces a string into a valid dns label of at most max characters
+func dnsLabel(s string, max int) string {
+	s = invalidNameChars.ReplaceAllString(strings.ToLower(s), "-")
+	if len(s) > max {
+		s = s[:max]
+	}
+
+	return strings.Trim(s, "-")
+}
+
+func IsJobFinished(job *batchv1.Job) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+
+		if condition.Type == batchv1.JobComplete || condition.Type == batchv1.JobFailed {
+			return true
+		}
+	}
+
+	return false
+}
+
 type PrefixMap map[string]string
 
 func (m PrefixMap) prefixed(s string) string {
@@ -21,7 +63,7 @@ func (m PrefixMap) prefixed(s string) string {
 }
 
 func (m PrefixMap) unprefixed(s string) string {
-	key, _ := strings.CutPrefix(s, prefixKey)
+	key, _ := strings.CutPrefix(s, prefixKey+"/")
 	return key
 }
 
@@ -80,3 +122,33 @@ func (m EnvMap) EnvVarList() []corev1.EnvVar {
 
 	return result
 }
+
+// when the pod was bound to a node, zero if it has not been
+func PodScheduledAt(pod *corev1.Pod) time.Time {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionTrue {
+			return condition.LastTransitionTime.Time
+		}
+	}
+
+	return time.Time{}
+}
+
+// when the runner container started, zero if it has not
+func RunnerStartedAt(pod *corev1.Pod) time.Time {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != RunnerContainerName {
+			continue
+		}
+
+		if status.State.Running != nil {
+			return status.State.Running.StartedAt.Time
+		}
+
+		if status.State.Terminated != nil {
+			return status.State.Terminated.StartedAt.Time
+		}
+	}
+
+	return time.Time{}
+}
@@ -0,0 +1,213 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	IndexRunner      = "runner"
+	IndexJob         = "job"
+	IndexWorkflowJob = "workflow-job"
+)
+
+// local view of the jobs and pods managed by the dispatcher
+type Informer struct {
+	factory informers.SharedInformerFactory
+	jobs    cache.SharedIndexInformer
+	pods    cache.SharedIndexInformer
+}
+
+var informer *Informer
+
+func (c *Client) NewInformer() (*Informer, error) {
+	factory := informers.NewSharedInformerFactoryWithOptions(
+		c.client,
+		0,
+		informers.WithNamespace(c.namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = JobSelector.String()
+		}),
+	)
+
+	jobs := factory.Batch().V1().Jobs().Informer()
+	if err := jobs.AddIndexers(cache.Indexers{
+		IndexRunner:      indexByLabel(RunnerLabelKey),
+		IndexWorkflowJob: indexByLabel(WorkflowJobLabelKey),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to add job indexers: %s", err)
+	}
+
+	pods := factory.Core().V1().Pods().Informer()
+	if err := pods.AddIndexers(cache.Indexers{
+		IndexRunner: indexByLabel(RunnerLabelKey),
+		IndexJob:    indexByLabel(batchv1.JobNameLabel, "job-name"),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to add pod indexers: %s", err)
+	}
+
+	return &Informer{factory, jobs, pods}, nil
+}
+
+// starts watching and blocks until the initial list has been synced
+func (i *Informer) Start(ctx context.Context) error {
+	i.factory.Start(ctx.Done())
+	for kind, synced := range i.factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("failed to sync informer cache for %s", kind)
+		}
+	}
+
+	return nil
+}
+
+func StartInformer(ctx context.Context) error {
+	client, err := GetClient()
+	if err != nil {
+		return fmt.Errorf("failed to get kubernetes client: %s", err)
+	}
+
+	i, err := client.NewInformer()
+	if err != nil {
+		return err
+	}
+
+	if err := i.Start(ctx); err != nil {
+		return err
+	}
+
+	informer = i
+	return nil
+}
+
+func getInformer() (*Informer, error) {
+	if informer == nil {
+		return nil, fmt.Errorf("informer not started")
+	}
+
+	return informer, nil
+}
+
+// registers a handler for job add/update/delete events
+func OnJobEvent(handler cache.ResourceEventHandler) error {
+	i, err := getInformer()
+	if err != nil {
+		return err
+	}
+
+	if _, err := i.jobs.AddEventHandler(handler); err != nil {
+		return fmt.Errorf("failed to add job event handler: %s", err)
+	}
+
+	return nil
+}
+
+// registers a handler for pod add/update/delete events
+func OnPodEvent(handler cache.ResourceEventHandler) error {
+	i, err := getInformer()
+	if err != nil {
+		return err
+	}
+
+	if _, err := i.pods.AddEventHandler(handler); err != nil {
+		return fmt.Errorf("failed to add pod event handler: %s", err)
+	}
+
+	return nil
+}
+
+// objects returned are shared with the informer and must not be modified
+func (i *Informer) ListJobs() []*batchv1.Job {
+	return typed[*batchv1.Job](i.jobs.GetStore().List())
+}
+
+func (i *Informer) ListJobsByIndex(index, value string) ([]*batchv1.Job, error) {
+	items, err := i.jobs.GetIndexer().ByIndex(index, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs by %s: %s", index, err)
+	}
+
+	return typed[*batchv1.Job](items), nil
+}
+
+func (i *Informer) ListPodsByIndex(index, value string) ([]*corev1.Pod, error) {
+	items, err := i.pods.GetIndexer().ByIndex(index, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods by %s: %s", index, err)
+	}
+
+	return typed[*corev1.Pod](items), nil
+}
+
+func ListJobs() ([]*batchv1.Job, error) {
+	i, err := getInformer()
+	if err != nil {
+		return nil, err
+	}
+
+	return i.ListJobs(), nil
+}
+
+// jobs dispatched for the runner config with the given id
+func ListJobsByRunner(id string) ([]*batchv1.Job, error) {
+	i, err := getInformer()
+	if err != nil {
+		return nil, err
+	}
+
+	return i.ListJobsByIndex(IndexRunner, id)
+}
+
+func ListJobsByWorkflowJob(id int64) ([]*batchv1.Job, error) {
+	i, err := getInformer()
+	if err != nil {
+		return nil, err
+	}
+
+	return i.ListJobsByIndex(IndexWorkflowJob, fmt.Sprint(id))
+}
+
+func ListPodsByJob(name string) ([]*corev1.Pod, error) {
+	i, err := getInformer()
+	if err != nil {
+		return nil, err
+	}
+
+	return i.ListPodsByIndex(IndexJob, name)
+}
+
+// indexes on the first label key present
+func indexByLabel(keys ...string) cache.IndexFunc {
+	return func(obj any) ([]string, error) {
+		object, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, key := range keys {
+			if value, ok := object.GetLabels()[key]; ok {
+				return []string{value}, nil
+			}
+		}
+
+		return []string{}, nil
+	}
+}
+
+func typed[T any](items []any) []T {
+	results := make([]T, 0, len(items))
+	for _, item := range items {
+		if typed, ok := item.(T); ok {
+			results = append(results, typed)
+		}
+	}
+
+	return results
+}
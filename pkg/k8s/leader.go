@@ -0,0 +1,95 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/axatol/actions-job-dispatcher/pkg/config"
+	"github.com/rs/zerolog/log"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+var (
+	leading atomic.Bool
+	leader  atomic.Value
+)
+
+// always true if leader election is disabled
+func IsLeader() bool {
+	if !config.LeaderElection {
+		return true
+	}
+
+	return leading.Load()
+}
+
+// identity of the current leader, empty if unknown
+func Leader() string {
+	if identity, ok := leader.Load().(string); ok {
+		return identity
+	}
+
+	return ""
+}
+
+// blocks until the context is cancelled, campaigning again whenever leadership
+// is lost
+func (c *Client) RunLeaderElection(ctx context.Context, onStartedLeading func(context.Context)) error {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta: metav1.ObjectMeta{
+			Name:      config.LeaderElectionLeaseName,
+			Namespace: c.namespace,
+		},
+		Client: c.client.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: config.LeaderElectionIdentity,
+		},
+	}
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		Name:            config.LeaderElectionLeaseName,
+		ReleaseOnCancel: true,
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				leading.Store(true)
+				log.Info().Str("identity", config.LeaderElectionIdentity).Msg("started leading")
+				onStartedLeading(ctx)
+			},
+			OnStoppedLeading: func() {
+				leading.Store(false)
+				log.Warn().Str("identity", config.LeaderElectionIdentity).Msg("stopped leading")
+			},
+			OnNewLeader: func(identity string) {
+				leader.Store(identity)
+				log.Info().Str("leader", identity).Msg("observed new leader")
+			},
+		},
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to create leader elector: %s", err)
+	}
+
+	for ctx.Err() == nil {
+		elector.Run(ctx)
+	}
+
+	return nil
+}
+
+func RunLeaderElection(ctx context.Context, onStartedLeading func(context.Context)) error {
+	client, err := GetClient()
+	if err != nil {
+		return fmt.Errorf("failed to get kubernetes client: %s", err)
+	}
+
+	return client.RunLeaderElection(ctx, onStartedLeading)
+}
@@ -27,39 +27,39 @@ func Version() (*version.Info, error) {
 	return client.Version()
 }
 
-func (c *Client) ListJobs(ctx context.Context) ([]batchv1.Job, error) {
-	opts := metav1.ListOptions{LabelSelector: JobSelector.String()}
-	jobs, err := c.client.BatchV1().Jobs(c.namespace).List(ctx, opts)
+func (c *Client) CreateJob(ctx context.Context, job batchv1.Job) (*batchv1.Job, error) {
+	response, err := c.client.BatchV1().Jobs(job.Namespace).Create(ctx, &job, metav1.CreateOptions{})
 	if err != nil {
-		return nil, fmt.Errorf("failed to list jobs: %s", err)
+		return nil, fmt.Errorf("failed to create job %s/%s: %s", job.Namespace, job.Name, err)
 	}
 
-	return jobs.Items, nil
+	return response, nil
 }
 
-func ListJobs(ctx context.Context) ([]batchv1.Job, error) {
+func CreateJob(ctx context.Context, job batchv1.Job) (*batchv1.Job, error) {
 	client, err := GetClient()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get kubernetes client: %s", err)
 	}
 
-	return client.ListJobs(ctx)
+	return client.CreateJob(ctx, job)
 }
 
-func (c *Client) CreateJob(ctx context.Context, job batchv1.Job) (*batchv1.Job, error) {
-	response, err := c.client.BatchV1().Jobs(job.Namespace).Create(ctx, &job, metav1.CreateOptions{})
-	if err != nil {
-		return nil, fmt.Errorf("failed to create job %s/%s: %s", job.Namespace, job.Name, err)
+func (c *Client) DeleteJob(ctx context.Context, name string) error {
+	propagation := metav1.DeletePropagationBackground
+	opts := metav1.DeleteOptions{PropagationPolicy: &propagation}
+	if err := c.client.BatchV1().Jobs(c.namespace).Delete(ctx, name, opts); err != nil {
+		return fmt.Errorf("failed to delete job %s/%s: %s", c.namespace, name, err)
 	}
 
-	return response, nil
+	return nil
 }
 
-func CreateJob(ctx context.Context, job batchv1.Job) (*batchv1.Job, error) {
+func DeleteJob(ctx context.Context, name string) error {
 	client, err := GetClient()
 	if err != nil {
-		return nil, fmt.Errorf("failed to get kubernetes client: %s", err)
+		return fmt.Errorf("failed to get kubernetes client: %s", err)
 	}
 
-	return client.CreateJob(ctx, job)
+	return client.DeleteJob(ctx, name)
 }
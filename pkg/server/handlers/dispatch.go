@@ -0,0 +1,36 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/axatol/actions-job-dispatcher/pkg/controller"
+	"github.com/go-chi/chi/v5"
+)
+
+func ListDispatches(w http.ResponseWriter, r *http.Request) {
+	results := struct {
+		Pending []controller.DispatchRequest `json:"pending"`
+		Waiting []controller.DispatchRequest `json:"waiting"`
+		Failed  []controller.FailedDispatch  `json:"failed"`
+	}{
+		Pending: controller.ListPendingDispatches(),
+		Waiting: controller.ListWaitingDispatches(),
+		Failed:  controller.ListFailedDispatches(),
+	}
+
+	ResponseOK().SetData(results).Write(w)
+}
+
+func RetryDispatch(w http.ResponseWriter, r *http.Request) {
+	key := chi.URLParam(r, "key")
+	if !controller.RetryFailedDispatch(key) {
+		ResponseErr(fmt.Errorf("no failed dispatch with key %s", key)).
+			SetStatus(http.StatusNotFound).
+			SetMessage("failed dispatch not found").
+			Write(w)
+		return
+	}
+
+	ResponseOK().SetStatus(http.StatusAccepted).SetMessage("dispatch queued").Write(w)
+}
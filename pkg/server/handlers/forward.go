@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/axatol/actions-job-dispatcher/pkg/config"
+	"github.com/axatol/actions-job-dispatcher/pkg/k8s"
+)
+
+// set on webhooks relayed by a follower to prevent forwarding loops
+const headerForwardedBy = "X-Dispatcher-Forwarded-By"
+
+var forwardClient = http.Client{Timeout: 5 * time.Second}
+
+// relays a webhook payload to the current leader
+func forwardToLeader(r *http.Request, payload []byte) error {
+	leader := k8s.Leader()
+	if leader == "" {
+		return fmt.Errorf("no leader elected")
+	}
+
+	if from := r.Header.Get(headerForwardedBy); from != "" {
+		return fmt.Errorf("webhook already forwarded by %s", from)
+	}
+
+	url := fmt.Sprintf("http://%s%s", leader, r.URL.Path)
+	req, err := http.NewRequestWithContext(r.Context(), http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create forwarding request: %s", err)
+	}
+
+	req.Header = r.Header.Clone()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(headerForwardedBy, config.LeaderElectionIdentity)
+
+	res, err := forwardClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to forward webhook to %s: %s", leader, err)
+	}
+
+	defer res.Body.Close()
+	if res.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("leader %s responded with status %d", leader, res.StatusCode)
+	}
+
+	return nil
+}
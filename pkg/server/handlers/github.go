@@ -1,11 +1,12 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/axatol/actions-job-dispatcher/pkg/cache"
-	"github.com/axatol/actions-job-dispatcher/pkg/config"
 	"github.com/axatol/actions-job-dispatcher/pkg/controller"
+	"github.com/axatol/actions-job-dispatcher/pkg/k8s"
 	"github.com/google/go-github/v51/github"
 	"github.com/rs/zerolog/log"
 )
@@ -30,6 +31,14 @@ func ReceiveGithubWebhook(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// github redelivers webhooks on request and on failure
+	deliveryID := github.DeliveryID(r)
+	if deliveryID != "" && !cache.MarkDelivery(deliveryID) {
+		log.Info().Msg("ignoring duplicate delivery")
+		ResponseOK().SetMessage("duplicate delivery").Write(w, log)
+		return
+	}
+
 	switch e := event.(type) {
 	case *github.PingEvent:
 		log.Info().Msg("responding to ping")
@@ -53,33 +62,47 @@ func ReceiveGithubWebhook(w http.ResponseWriter, r *http.Request) {
 			Strs("workflow_job_labels", e.GetWorkflowJob().Labels).
 			Logger()
 
-		runner, err := controller.SelectRunner(e)
+		runner, err := controller.AcceptWorkflowJobEvent(e)
+		if errors.Is(err, controller.ErrDuplicateTransition) {
+			log.Info().Msg("ignoring duplicate workflow job transition")
+			ResponseOK().SetMessage("duplicate transition").Write(w, log)
+			return
+		}
+
 		if err != nil {
 			log.Debug().Err(err).Msg("ignoring workflow_job webhook")
 			ResponseOK().Write(w)
 			return
 		}
 
-		cache.CacheWorkflowJobEvent(e)
-		if e.GetAction() == "queued" {
-			runner = &config.RunnerConfig{
-				Image:              runner.Image,
-				Labels:             runner.Labels,
-				Resources:          runner.Resources,
-				ServiceAccountName: runner.ServiceAccountName,
-				Scope: config.Scope{
-					IsOrg:      runner.Scope.IsOrg,
-					Owner:      runner.Scope.Owner,
-					Repository: e.GetRepo().GetName(),
-				},
-			}
-
-			if err := controller.Dispatch(r.Context(), *runner); err != nil {
-				ResponseErr(err).SetMessage("failed to dispatch job").Write(w, log)
+		// only the leader dispatches, followers hand the event over
+		if !k8s.IsLeader() {
+			if err := forwardToLeader(r, payload); err != nil {
+				cache.UnmarkDelivery(deliveryID)
+				cache.UnmarkTransition(e.GetWorkflowJob().GetID(), e.GetAction())
+				ResponseErr(err).
+					SetStatus(http.StatusServiceUnavailable).
+					SetMessage("failed to forward webhook to leader").
+					Write(w, log)
 				return
 			}
+
+			log.Debug().Str("leader", k8s.Leader()).Msg("forwarded webhook to leader")
+			ResponseOK().Write(w)
+			return
+		}
+
+		if controller.HandleWorkflowJobEvent(e, *runner) {
+			ResponseOK().
+				SetStatus(http.StatusAccepted).
+				SetMessage("dispatch queued").
+				Write(w, log)
+			return
 		}
 
+		ResponseOK().Write(w)
+		return
+
 	default:
 		log.Info().Str("event_type", webhookType).Msg("ignoring webhook")
 		ResponseOK().Write(w)
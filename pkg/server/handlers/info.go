@@ -1,14 +1,21 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/axatol/actions-job-dispatcher/pkg/cache"
 	"github.com/axatol/actions-job-dispatcher/pkg/config"
+	"github.com/axatol/actions-job-dispatcher/pkg/controller"
 	"github.com/axatol/actions-job-dispatcher/pkg/gh"
 	"github.com/axatol/actions-job-dispatcher/pkg/k8s"
+	"github.com/go-chi/chi/v5"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
 )
 
 func Ping(w http.ResponseWriter, r *http.Request) {
@@ -66,10 +73,138 @@ func HealthCheck(w http.ResponseWriter, r *http.Request) {
 	ResponseOK().SetData(results).Write(w)
 }
 
+type runnerConfig struct {
+	config.RunnerConfig
+	ActiveWindow       *config.ScalingWindow `json:"active_window"`
+	CurrentMinIdle     int                   `json:"current_min_idle"`
+	CurrentMaxReplicas int                   `json:"current_max_replicas"`
+}
+
 func ListRunners(w http.ResponseWriter, r *http.Request) {
-	ResponseOK().SetData(config.Runners).Write(w)
+	now := time.Now()
+	results := []runnerConfig{}
+	for _, runner := range config.Runners {
+		current := runner.At(now)
+		results = append(results, runnerConfig{
+			RunnerConfig:       runner,
+			ActiveWindow:       runner.Schedules.ActiveAt(now),
+			CurrentMinIdle:     current.MinIdle,
+			CurrentMaxReplicas: current.MaxReplicas,
+		})
+	}
+
+	ResponseOK().SetData(results).Write(w)
+}
+
+func ListRemovedRunners(w http.ResponseWriter, r *http.Request) {
+	ResponseOK().SetData(controller.LastRemovedRunners()).Write(w)
+}
+
+type runnerJob struct {
+	Name        string                     `json:"name"`
+	Runner      string                     `json:"runner"`
+	Scope       string                     `json:"scope"`
+	Finished    bool                       `json:"finished"`
+	CreatedAt   time.Time                  `json:"created_at"`
+	Pods        map[string]corev1.PodPhase `json:"pods"`
+	WorkflowJob *cache.WorkflowJobMeta     `json:"workflow_job"`
 }
 
 func ListJobs(w http.ResponseWriter, r *http.Request) {
-	ResponseOK().SetData(cache.List()).Write(w)
+	jobs, err := k8s.ListJobs()
+	if err != nil {
+		ResponseErr(err).SetMessage("failed to list jobs").Write(w)
+		return
+	}
+
+	runnerJobs, err := newRunnerJobs(jobs)
+	if err != nil {
+		ResponseErr(err).SetMessage("failed to list pods").Write(w)
+		return
+	}
+
+	results := struct {
+		WorkflowJobs []cache.WorkflowJobMeta `json:"workflow_jobs"`
+		RunnerJobs   []runnerJob             `json:"runner_jobs"`
+	}{
+		WorkflowJobs: cache.List(),
+		RunnerJobs:   runnerJobs,
+	}
+
+	ResponseOK().SetData(results).Write(w)
+}
+
+func newRunnerJobs(jobs []*batchv1.Job) ([]runnerJob, error) {
+	runnerJobs := []runnerJob{}
+	for _, job := range jobs {
+		annotations := k8s.PrefixMapFromLabels(job.Annotations).Extract()
+		result := runnerJob{
+			Name:        job.Name,
+			Runner:      annotations["runner"],
+			Scope:       annotations["scope"],
+			Finished:    k8s.IsJobFinished(job),
+			CreatedAt:   job.CreationTimestamp.Time,
+			Pods:        map[string]corev1.PodPhase{},
+			WorkflowJob: controller.JobWorkflowJob(job),
+		}
+
+		pods, err := k8s.ListPodsByJob(job.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pod := range pods {
+			result.Pods[pod.Name] = pod.Status.Phase
+		}
+
+		runnerJobs = append(runnerJobs, result)
+	}
+
+	return runnerJobs, nil
+}
+
+// a cached or recently completed workflow job with its timeline and runners
+func GetJob(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		ResponseErr(err).SetStatus(http.StatusBadRequest).SetMessage("invalid workflow job id").Write(w)
+		return
+	}
+
+	meta := cache.Get(id)
+	if meta == nil {
+		meta = cache.GetHistory(id)
+	}
+
+	if meta == nil {
+		ResponseErr(fmt.Errorf("no workflow job with id %d", id)).
+			SetStatus(http.StatusNotFound).
+			SetMessage("workflow job not found").
+			Write(w)
+		return
+	}
+
+	jobs, err := k8s.ListJobsByWorkflowJob(id)
+	if err != nil {
+		ResponseErr(err).SetMessage("failed to list jobs").Write(w)
+		return
+	}
+
+	runnerJobs, err := newRunnerJobs(jobs)
+	if err != nil {
+		ResponseErr(err).SetMessage("failed to list pods").Write(w)
+		return
+	}
+
+	results := struct {
+		WorkflowJob cache.WorkflowJobMeta   `json:"workflow_job"`
+		Durations   cache.TimelineDurations `json:"durations"`
+		RunnerJobs  []runnerJob             `json:"runner_jobs"`
+	}{
+		WorkflowJob: *meta,
+		Durations:   meta.Timeline.Durations(),
+		RunnerJobs:  runnerJobs,
+	}
+
+	ResponseOK().SetData(results).Write(w)
 }
@@ -21,7 +21,11 @@ func NewServer(serverPort int64) *http.Server {
 	router.Get("/ping", handlers.Ping)
 	router.Get("/health", handlers.HealthCheck)
 	router.Get("/runners", handlers.ListRunners)
+	router.Get("/runners/removed", handlers.ListRemovedRunners)
 	router.Get("/jobs", handlers.ListJobs)
+	router.Get("/jobs/{id}", handlers.GetJob)
+	router.Get("/dispatches", handlers.ListDispatches)
+	router.Post("/dispatches/{key}/retry", handlers.RetryDispatch)
 	router.Post("/webhook", handlers.ReceiveGithubWebhook)
 
 	addr := fmt.Sprintf(":%d", serverPort)
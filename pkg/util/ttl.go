@@ -0,0 +1,91 @@
+package util
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// a goroutine-safe set whose keys expire after a fixed ttl, holding at most
+// size keys by evicting the oldest
+type TTLSet struct {
+	lock    sync.Mutex
+	ttl     time.Duration
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type ttlEntry struct {
+	key     string
+	expires time.Time
+}
+
+func NewTTLSet(ttl time.Duration, size int) *TTLSet {
+	return &TTLSet{
+		ttl:     ttl,
+		size:    size,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+	}
+}
+
+// adds the key, returns false if it was already present
+func (s *TTLSet) Add(key string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.expire(time.Now())
+	if _, ok := s.entries[key]; ok {
+		return false
+	}
+
+	s.entries[key] = s.order.PushBack(ttlEntry{key, time.Now().Add(s.ttl)})
+	for s.size > 0 && s.order.Len() > s.size {
+		s.remove(s.order.Front())
+	}
+
+	return true
+}
+
+func (s *TTLSet) Has(key string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.expire(time.Now())
+	_, ok := s.entries[key]
+	return ok
+}
+
+func (s *TTLSet) Del(key string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if element, ok := s.entries[key]; ok {
+		s.remove(element)
+	}
+}
+
+func (s *TTLSet) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.expire(time.Now())
+	return s.order.Len()
+}
+
+// entries share a ttl so the oldest always expires first
+func (s *TTLSet) expire(now time.Time) {
+	for element := s.order.Front(); element != nil; element = s.order.Front() {
+		if element.Value.(ttlEntry).expires.After(now) {
+			return
+		}
+
+		s.remove(element)
+	}
+}
+
+func (s *TTLSet) remove(element *list.Element) {
+	delete(s.entries, element.Value.(ttlEntry).key)
+	s.order.Remove(element)
+}